@@ -0,0 +1,168 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/codecommit"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	codecommitsign "github.com/gembaadvantage/codecommit-sign"
+	"github.com/gembaadvantage/codecommit-sign/pkg/retry"
+)
+
+// The policy for retrying transient failures when retrieving credentials, configured
+// through the --max-attempts and --max-backoff flags
+var credentialsRetry = retry.Policy{
+	MaxAttempts: retry.DefaultMaxAttempts,
+	MaxBackoff:  retry.DefaultMaxBackoff,
+}
+
+// Credentials are refreshed within this window of expiring, ensuring none are
+// handed out on the verge of expiring
+const credentialsExpiryWindow = 5 * time.Minute
+
+func withExpiryWindow(o *aws.CredentialsCacheOptions) {
+	o.ExpiryWindow = credentialsExpiryWindow
+}
+
+// Loads the default AWS config, optionally overriding the named profile and region
+func loadAWSConfig(ctx context.Context, profile, region string) (aws.Config, error) {
+	// Explicit credentials bypass the default config, which may otherwise probe the network
+	if staticCredentials != nil {
+		return staticConfig(region), nil
+	}
+
+	// Dynamically load options. The SDK always caches the credentials it resolves, so
+	// the expiry window must be applied to its cache rather than one wrapping it. The
+	// SSO and STS clients behind those credentials make a single attempt, leaving the
+	// retry policy as the only layer of retries
+	opts := []func(*config.LoadOptions) error{
+		config.WithCredentialsCacheOptions(withExpiryWindow),
+		config.WithRetryMaxAttempts(1),
+	}
+	if profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	}
+
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return cfg, err
+	}
+
+	// Absorb any throttling from STS, SSO or IMDS when many clients retrieve credentials at once
+	cfg.Credentials = retry.Provider{Provider: cfg.Credentials, Policy: credentialsRetry}
+	return cfg, nil
+}
+
+// Chains an assumed role onto the credentials resolved within the config. STS
+// defaults to us-east-1 if no region has been configured
+func assumeRole(cfg aws.Config, roleARN, sessionName string, duration time.Duration) aws.CredentialsProvider {
+	client := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if o.Region == "" {
+			o.Region = "us-east-1"
+		}
+	})
+
+	provider := stscreds.NewAssumeRoleProvider(client, roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName
+		if duration > 0 {
+			o.Duration = duration
+		}
+	})
+
+	return aws.NewCredentialsCache(retry.Provider{Provider: provider, Policy: credentialsRetry}, withExpiryWindow)
+}
+
+// Creates a client for signing CodeCommit URLs, sharing the credentials, retry
+// policy and logger of every command
+func newClient(profile, region string, instanceMetadata bool, opts ...codecommitsign.Option) *codecommitsign.Client {
+	opts = append([]codecommitsign.Option{
+		codecommitsign.WithProfile(profile),
+		codecommitsign.WithRegion(region),
+		codecommitsign.WithRetry(credentialsRetry.MaxAttempts, credentialsRetry.MaxBackoff),
+		codecommitsign.WithLogger(slog.Default()),
+	}, opts...)
+
+	if instanceMetadata {
+		opts = append(opts, codecommitsign.WithInstanceMetadata())
+	}
+
+	// Explicit credentials bypass the default config, which may otherwise probe the network
+	if staticCredentials != nil {
+		creds := *staticCredentials
+		opts = append(opts, codecommitsign.WithCredentials(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return creds, nil
+		})))
+	}
+	return codecommitsign.New(opts...)
+}
+
+// Builds a config around explicitly provided credentials, without reading any shared
+// config files. The region falls back to the AWS_REGION or AWS_DEFAULT_REGION
+// environment variables
+func staticConfig(region string) aws.Config {
+	cfg := aws.NewConfig()
+
+	cfg.Region = region
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if cfg.Region == "" {
+			cfg.Region = os.Getenv(env)
+		}
+	}
+
+	creds := *staticCredentials
+	cfg.Credentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return creds, nil
+	})
+	return *cfg
+}
+
+// Creates a new CodeCommit client, optionally overriding the endpoint used to
+// connect to the CodeCommit API
+func newCodeCommitClient(cfg aws.Config, endpoint string) (*codecommit.Client, error) {
+	if cfg.Region == "" {
+		return nil, errors.New("no aws region identified")
+	}
+
+	return codecommit.NewFromConfig(cfg, func(o *codecommit.Options) {
+		// Only credentials are retried through the retry policy, so restore the
+		// default retries of the SDK for API calls
+		o.RetryMaxAttempts = 0
+
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/repos"
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cloneAllDesc = `Clone every CodeCommit repository within an AWS account and region into a
+local directory, using signed URLs. Any repository that has already been cloned
+will be fetched instead, so it is safe to run repeatedly against the same directory.
+
+Signed URLs are never persisted within the git config of a cloned repository`
+
+	cloneAllExs = `Clone all repositories into the current directory:
+
+$ codecommit-sign clone-all
+
+Clone all repositories prefixed with service- into a backup directory:
+
+$ codecommit-sign clone-all --filter 'service-*' --parallel 8 backup`
+)
+
+type cloneAllOptions struct {
+	listOptions
+	Dir      string
+	Parallel int
+}
+
+func newCloneAllCmd(out io.Writer) *cobra.Command {
+	opts := cloneAllOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "clone-all [DIR]",
+		Short:   "Clone or fetch all CodeCommit repositories within an AWS account and region",
+		Long:    cloneAllDesc,
+		Example: cloneAllExs,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Dir = "."
+			if len(args) > 0 {
+				opts.Dir = args[0]
+			}
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to use when looking up credentials")
+	f.StringVar(&opts.Region, "region", "", "the AWS region to clone repositories from")
+	f.StringVar(&opts.Endpoint, "endpoint-url", "", "override the endpoint used to connect to the CodeCommit API")
+	f.StringVar(&opts.Filter, "filter", "", "only include repositories whose name matches a glob pattern")
+	f.IntVar(&opts.Parallel, "parallel", 4, "the number of repositories to clone in parallel")
+
+	return cmd
+}
+
+func (o cloneAllOptions) Run(ctx context.Context, out io.Writer) error {
+	cfg, err := loadAWSConfig(ctx, o.Profile, o.Region)
+	if err != nil {
+		fmt.Fprintln(out, "\u26a0\ufe0f  failed to retrieve default AWS config")
+		return err
+	}
+
+	client, err := newCodeCommitClient(cfg, o.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	names, err := repos.List(ctx, client, o.Filter)
+	if err != nil {
+		return err
+	}
+
+	urls := make([]string, 0, len(names))
+	for _, name := range names {
+		u, err := translate.ToHTTPS(translate.Remote{Region: cfg.Region, Repository: name})
+		if err != nil {
+			return err
+		}
+		urls = append(urls, u)
+	}
+
+	c := repos.Cloner{
+		Dir:      o.Dir,
+		Parallel: o.Parallel,
+		Sign:     newClient(o.Profile, cfg.Region, false).Sign,
+	}
+
+	failed := 0
+	for _, res := range c.CloneAll(ctx, urls) {
+		switch {
+		case res.Err != nil:
+			failed++
+			slog.Error("failed to clone repository", "repository", res.Repository, "region", cfg.Region, "error", res.Err)
+			fmt.Fprintf(out, "\u26a0\ufe0f  failed to clone %s: %s\n", res.Repository, res.Err)
+		case res.Fetched:
+			slog.Info("fetched repository", "repository", res.Repository, "region", cfg.Region, "dir", res.Dir)
+			fmt.Fprintf(out, "fetched %s\n", res.Repository)
+		default:
+			slog.Info("cloned repository", "repository", res.Repository, "region", cfg.Region, "dir", res.Dir)
+			fmt.Fprintf(out, "cloned %s\n", res.Repository)
+		}
+	}
+
+	if failed > 0 {
+		return errors.New("failed to clone all repositories")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Derives the context used to run a command, bounded by the --timeout flag. The
+// parent context is cancelled on SIGINT or SIGTERM
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if timeout := commandTimeout(cmd); timeout > 0 {
+		return context.WithTimeout(parentContext(cmd), timeout)
+	}
+	return context.WithCancel(parentContext(cmd))
+}
+
+// Derives the context used to run a long-lived command, which is only cancelled on
+// SIGINT or SIGTERM. Such commands apply the --timeout flag to each unit of work
+func daemonContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return context.WithCancel(parentContext(cmd))
+}
+
+// Returns the value of the --timeout flag, zero if disabled
+func commandTimeout(cmd *cobra.Command) time.Duration {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	return timeout
+}
+
+func parentContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
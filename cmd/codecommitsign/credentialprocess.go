@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/credproc"
+	"github.com/spf13/cobra"
+)
+
+const credentialProcessExs = `Reference from a profile within ~/.aws/config:
+
+[profile codecommit]
+credential_process = codecommit-sign credential-process --profile source --role-arn arn:aws:iam::123456789012:role/CodeCommit`
+
+type credentialProcessOptions struct {
+	Profile         string
+	Region          string
+	RoleARN         string
+	RoleSessionName string
+	Duration        time.Duration
+}
+
+func newCredentialProcessCmd(out io.Writer) *cobra.Command {
+	opts := credentialProcessOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "credential-process",
+		Short: "Print AWS credentials in the credential_process JSON format",
+		Long: `Print AWS credentials in the credential_process JSON format, resolved in the same way
+as when signing a URL, optionally assuming a role. Allows any tool that supports
+credential_process to share the same credentials`,
+		Example: credentialProcessExs,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out, cmd.ErrOrStderr())
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to use when looking up credentials")
+	f.StringVar(&opts.Region, "region", "", "the AWS region used when assuming a role through STS")
+	f.StringVar(&opts.RoleARN, "role-arn", "", "the ARN of a role to assume, chained onto the resolved credentials")
+	f.StringVar(&opts.RoleSessionName, "role-session-name", "codecommit-sign", "the session name used when assuming a role")
+	f.DurationVar(&opts.Duration, "duration", 0, "the duration of the assumed role session, defaults to 15m")
+
+	return cmd
+}
+
+func (o credentialProcessOptions) Run(ctx context.Context, out, errOut io.Writer) error {
+	// Only credentials can be written to stdout, as it is parsed by the calling tool
+	cfg, err := loadAWSConfig(ctx, o.Profile, o.Region)
+	if err != nil {
+		fmt.Fprintln(errOut, "\u26a0\ufe0f  failed to retrieve default AWS config")
+		return err
+	}
+
+	provider := cfg.Credentials
+	if o.RoleARN != "" {
+		provider = assumeRole(cfg, o.RoleARN, o.RoleSessionName, o.Duration)
+	}
+
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		if msg := ssoRemediation(ctx, o.Profile); msg != "" {
+			fmt.Fprintln(errOut, msg)
+		} else {
+			fmt.Fprintln(errOut, "\u26a0\ufe0f  failed to retrieve AWS credentials")
+		}
+		return err
+	}
+
+	return credproc.Write(out, creds)
+}
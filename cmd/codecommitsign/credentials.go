@@ -0,0 +1,138 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/gembaadvantage/codecommit-sign/pkg/credproc"
+)
+
+// Explicitly provided credentials that bypass the default AWS credential chain,
+// configured through the credential flags
+var staticCredentials *aws.Credentials
+
+type credentialOptions struct {
+	AccessKeyID          string
+	SecretAccessKey      string
+	SecretAccessKeyStdin bool
+	SessionToken         string
+	SessionTokenStdin    bool
+	File                 string
+	Insecure             bool
+}
+
+// Resolves any explicitly provided credentials. When both a secret access key and
+// session token are read from stdin, they are expected on separate lines, in that order
+func (o credentialOptions) install(stdin io.Reader) error {
+	staticCredentials = nil
+
+	if (o.SecretAccessKey != "" || o.SessionToken != "") && !o.Insecure {
+		return errors.New("secrets provided as arguments can be exposed through the process list and shell history, " +
+			"use --secret-access-key-stdin, --session-token-stdin or --credentials-file instead, or explicitly opt in with --insecure")
+	}
+
+	if o.File != "" {
+		if o.AccessKeyID != "" || o.SecretAccessKey != "" || o.SecretAccessKeyStdin || o.SessionToken != "" || o.SessionTokenStdin {
+			return errors.New("--credentials-file cannot be combined with any other credential flags")
+		}
+		return o.installFile()
+	}
+
+	if o.AccessKeyID == "" {
+		if o.SecretAccessKey != "" || o.SecretAccessKeyStdin || o.SessionToken != "" || o.SessionTokenStdin {
+			return errors.New("--access-key-id must be provided alongside a secret access key")
+		}
+		return nil
+	}
+
+	if o.SecretAccessKey != "" && o.SecretAccessKeyStdin {
+		return errors.New("--secret-access-key and --secret-access-key-stdin cannot be combined")
+	}
+
+	if o.SessionToken != "" && o.SessionTokenStdin {
+		return errors.New("--session-token and --session-token-stdin cannot be combined")
+	}
+
+	creds := aws.Credentials{
+		AccessKeyID:     o.AccessKeyID,
+		SecretAccessKey: o.SecretAccessKey,
+		SessionToken:    o.SessionToken,
+		Source:          "FlagCredentials",
+	}
+
+	s := bufio.NewScanner(stdin)
+	if o.SecretAccessKeyStdin {
+		if creds.SecretAccessKey = scanLine(s); creds.SecretAccessKey == "" {
+			return errors.New("no secret access key provided through stdin")
+		}
+	}
+
+	if o.SessionTokenStdin {
+		if creds.SessionToken = scanLine(s); creds.SessionToken == "" {
+			return errors.New("no session token provided through stdin")
+		}
+	}
+
+	if creds.SecretAccessKey == "" {
+		return errors.New("a secret access key must be provided with --secret-access-key-stdin")
+	}
+
+	staticCredentials = &creds
+	return nil
+}
+
+func (o credentialOptions) installFile() error {
+	f, err := os.Open(o.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	creds, err := credproc.Read(f)
+	if err != nil {
+		return fmt.Errorf("reading credentials file %s: %w", o.File, err)
+	}
+
+	if creds.Expired() {
+		return fmt.Errorf("credentials within %s expired at %s", o.File, creds.Expires.Format(time.RFC3339))
+	}
+
+	creds.Source = "FileCredentials"
+	staticCredentials = &creds
+	return nil
+}
+
+func scanLine(s *bufio.Scanner) string {
+	if !s.Scan() {
+		return ""
+	}
+	return strings.TrimSpace(s.Text())
+}
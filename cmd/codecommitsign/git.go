@@ -0,0 +1,113 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/gitauth"
+	"github.com/gembaadvantage/codecommit-sign/pkg/redact"
+	"github.com/spf13/cobra"
+)
+
+const (
+	gitDesc = `Run any git command against a CodeCommit repository without persisting credentials.
+
+Arguments are scanned for CodeCommit HTTPS and GRC URLs. GRC URLs are translated
+into their HTTPS equivalent, and signed credentials for each URL are passed to git
+as transient config. Each GRC URL is signed using any named profile embedded
+within it. As the URL itself is never signed, credentials are never written
+to .git/config`
+
+	gitExs = `Clone a CodeCommit repository using a GRC URL:
+
+$ codecommit-sign git clone codecommit::eu-west-1://repository
+
+Flags for codecommit-sign must appear before any git arguments:
+
+$ codecommit-sign git --profile profile -- clone --depth 1 codecommit::eu-west-1://repository`
+)
+
+type gitOptions struct {
+	Profile          string
+	Region           string
+	InstanceMetadata bool
+	Args             []string
+}
+
+func newGitCmd(out io.Writer) *cobra.Command {
+	opts := gitOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "git [flags] [--] GIT_ARGS...",
+		Short:   "Run a git command, authenticating against CodeCommit with transient credentials",
+		Long:    gitDesc,
+		Example: gitExs,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Args = args
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to use when looking up credentials, overrides any profile within a GRC URL")
+	f.StringVar(&opts.Region, "region", "", "the AWS region to use if one cannot be identified from a GRC URL")
+	f.BoolVar(&opts.InstanceMetadata, "instance-metadata", false, "resolve a region from EC2 or ECS metadata if all other sources fail")
+
+	// Stop parsing flags at the first git argument, ensuring git flags are passed through
+	f.SetInterspersed(false)
+
+	return cmd
+}
+
+func (o gitOptions) Run(ctx context.Context, out io.Writer) error {
+	client := newClient(o.Profile, o.Region, o.InstanceMetadata)
+
+	inv, err := gitauth.Rewrite(ctx, o.Args, client.Sign, client.Region)
+	if err != nil {
+		return redact.Error(err)
+	}
+
+	c := inv.Command(ctx, os.Environ())
+	c.Stdin = os.Stdin
+	c.Stdout = out
+	c.Stderr = os.Stderr
+
+	if err := c.Run(); err != nil {
+		// Exit with the same code as git, as it will have already reported the failure
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+
+	return nil
+}
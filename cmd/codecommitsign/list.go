@@ -0,0 +1,85 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/repos"
+	"github.com/spf13/cobra"
+)
+
+type listOptions struct {
+	Profile  string
+	Region   string
+	Endpoint string
+	Filter   string
+}
+
+func newListCmd(out io.Writer) *cobra.Command {
+	opts := listOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all CodeCommit repositories within an AWS account and region",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to use when looking up credentials")
+	f.StringVar(&opts.Region, "region", "", "the AWS region to list repositories from")
+	f.StringVar(&opts.Endpoint, "endpoint-url", "", "override the endpoint used to connect to the CodeCommit API")
+	f.StringVar(&opts.Filter, "filter", "", "only include repositories whose name matches a glob pattern")
+
+	return cmd
+}
+
+func (o listOptions) Run(ctx context.Context, out io.Writer) error {
+	cfg, err := loadAWSConfig(ctx, o.Profile, o.Region)
+	if err != nil {
+		fmt.Fprintln(out, "\u26a0\ufe0f  failed to retrieve default AWS config")
+		return err
+	}
+
+	client, err := newCodeCommitClient(cfg, o.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	names, err := repos.List(ctx, client, o.Filter)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Fprintln(out, name)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type logOptions struct {
+	Level  string
+	Format string
+}
+
+// Builds a leveled logger from the flags and installs it as the default logger. Library
+// packages are handed the default logger explicitly wherever they support one
+func (o logOptions) install(w io.Writer) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+		return fmt.Errorf("unsupported log level %q, must be one of debug, info, warn or error", o.Level)
+	}
+
+	hopts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(o.Format) {
+	case "text":
+		handler = slog.NewTextHandler(w, hopts)
+	case "json":
+		handler = slog.NewJSONHandler(w, hopts)
+	default:
+		return fmt.Errorf("unsupported log format %q, must be one of text or json", o.Format)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/gembaadvantage/codecommit-sign/pkg/sso"
+	"github.com/spf13/cobra"
+)
+
+type loginOptions struct {
+	Profile  string
+	Endpoint string
+}
+
+func newLoginCmd(out io.Writer) *cobra.Command {
+	opts := loginOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in to AWS IAM Identity Center (SSO) for a named profile",
+		Long: `Log in to AWS IAM Identity Center (SSO) using the device authorization flow, for a
+named profile configured with either an sso_session or sso_start_url. The cached
+token is shared with the AWS CLI and SDKs`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to log in with")
+	f.StringVar(&opts.Endpoint, "oidc-endpoint", "", "override the endpoint used to connect to the IAM Identity Center OIDC API")
+
+	return cmd
+}
+
+func (o loginOptions) Run(ctx context.Context, out io.Writer) error {
+	sess, ok, err := sso.Lookup(ctx, o.Profile, nil)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("profile %q is not configured for aws sso, it needs either an sso_session or sso_start_url", sess.Profile)
+	}
+
+	client := ssooidc.New(ssooidc.Options{Region: sess.Region}, func(opts *ssooidc.Options) {
+		if o.Endpoint != "" {
+			opts.BaseEndpoint = aws.String(o.Endpoint)
+		}
+	})
+
+	l := sso.Login{
+		Client:  client,
+		Session: sess,
+		Prompt: func(a sso.Authorization) {
+			fmt.Fprintf(out, "To log in, open the following URL within a browser:\n\n  %s\n\n", a.VerificationURIComplete)
+			fmt.Fprintf(out, "and confirm it displays the code: %s\n\n", a.UserCode)
+		},
+	}
+
+	tok, err := l.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "logged in to %s with profile %s until %s\n", sess.StartURL, sess.Profile, tok.ExpiresAt)
+	return nil
+}
+
+// Generates a precise remediation if credentials could not be retrieved due to a
+// missing or expired SSO token. Returns an empty string if SSO is not the cause
+func ssoRemediation(ctx context.Context, profile string) string {
+	sess, ok, err := sso.Lookup(ctx, profile, nil)
+	if err != nil || !ok {
+		return ""
+	}
+
+	var reason string
+	switch err := sess.Status(time.Now()); {
+	case errors.Is(err, sso.ErrNotLoggedIn):
+		reason = "you are not logged in"
+	case errors.Is(err, sso.ErrTokenExpired):
+		reason = "your session has expired"
+	default:
+		return ""
+	}
+
+	return fmt.Sprintf("\u26a0\ufe0f  failed to retrieve AWS credentials, profile %q uses AWS IAM Identity Center (SSO) and %s. "+
+		"To log in again, run either:\n\n  codecommit-sign login --profile %[1]s\n  aws sso login --profile %[1]s", sess.Profile, reason)
+}
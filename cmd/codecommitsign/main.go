@@ -22,12 +22,22 @@ SOFTWARE.
 
 package main
 
-import "os"
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
 
 func main() {
+	// Cancel any in-flight work, such as retrieving credentials, when interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
 	cmd := newRootCmd(os.Stdout, os.Args[1:])
+	err := cmd.ExecuteContext(ctx)
+	stop()
 
-	if err := cmd.Execute(); err != nil {
+	if err != nil {
 		os.Exit(1)
 	}
 }
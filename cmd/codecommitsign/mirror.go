@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/mirror"
+	"github.com/gembaadvantage/codecommit-sign/pkg/redact"
+	"github.com/spf13/cobra"
+)
+
+const (
+	mirrorDesc = `Mirror all refs from one git repository to another. Either side of the mirror
+can be a CodeCommit HTTPS or GRC URL, which will be signed on the fly, while any
+other URL is passed to git untouched. Each GRC URL is signed using any named
+profile embedded within it.
+
+Refs that are deleted from the source repository will also be deleted from the
+target repository. Mirroring can be restricted to a subset of refs by providing
+one or more ref patterns.
+
+By default a single mirror is performed. Providing an interval will instead
+continually mirror the repositories until the process is stopped`
+
+	mirrorExs = `Mirror a GitHub repository into CodeCommit:
+
+$ codecommit-sign mirror --from https://github.com/org/repository.git --to codecommit::eu-west-1://repository
+
+Mirror only branches and tags every 5 minutes:
+
+$ codecommit-sign mirror --from https://github.com/org/repository.git \
+  --to codecommit::eu-west-1://repository \
+  --ref 'refs/heads/*' --ref 'refs/tags/*' --interval 5m`
+)
+
+type mirrorOptions struct {
+	Profile          string
+	Region           string
+	InstanceMetadata bool
+	From             string
+	To               string
+	Refs             []string
+	Dir              string
+	Interval         time.Duration
+	Timeout          time.Duration
+}
+
+func newMirrorCmd(out io.Writer) *cobra.Command {
+	opts := mirrorOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "mirror",
+		Short:   "Mirror a git repository to or from CodeCommit",
+		Long:    mirrorDesc,
+		Example: mirrorExs,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The timeout bounds each mirror, rather than the entire interval loop
+			ctx, cancel := daemonContext(cmd)
+			defer cancel()
+
+			opts.Timeout = commandTimeout(cmd)
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to use when looking up credentials, overrides any profile within a GRC URL")
+	f.StringVar(&opts.Region, "region", "", "the AWS region to use if one cannot be identified from a GRC URL")
+	f.BoolVar(&opts.InstanceMetadata, "instance-metadata", false, "resolve a region from EC2 or ECS metadata if all other sources fail")
+	f.StringVar(&opts.From, "from", "", "the URL of the repository to mirror from")
+	f.StringVar(&opts.To, "to", "", "the URL of the repository to mirror to")
+	f.StringArrayVar(&opts.Refs, "ref", []string{}, "only mirror refs matching the pattern, can be repeated")
+	f.StringVar(&opts.Dir, "dir", "", "a local directory for caching the mirror between runs (default a temporary directory)")
+	f.DurationVar(&opts.Interval, "interval", 0, "continually mirror at the given interval")
+
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func (o mirrorOptions) Run(ctx context.Context, out io.Writer) error {
+	if o.Dir == "" {
+		var err error
+		if o.Dir, err = os.MkdirTemp("", "codecommit-sign-mirror"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(o.Dir)
+	}
+
+	client := newClient(o.Profile, o.Region, o.InstanceMetadata)
+	m := mirror.Mirror{
+		From:   o.From,
+		To:     o.To,
+		Refs:   o.Refs,
+		Dir:    o.Dir,
+		Sign:   client.Sign,
+		Region: client.Region,
+	}
+
+	for {
+		err := o.sync(ctx, m)
+		if o.Interval == 0 {
+			return err
+		}
+
+		if err != nil {
+			slog.Error("failed to mirror repository", "from", redact.String(o.From), "to", redact.String(o.To), "error", err)
+			fmt.Fprintf(out, "\u26a0\ufe0f  failed to mirror %s to %s: %s\n", redact.String(o.From), redact.String(o.To), err)
+		} else {
+			slog.Info("mirrored repository", "from", redact.String(o.From), "to", redact.String(o.To))
+			fmt.Fprintf(out, "mirrored %s to %s\n", redact.String(o.From), redact.String(o.To))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(o.Interval):
+		}
+	}
+}
+
+// Performs a single mirror, bounded by any timeout
+func (o mirrorOptions) sync(ctx context.Context, m mirror.Mirror) error {
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	return m.Sync(ctx)
+}
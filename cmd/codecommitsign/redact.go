@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/redact"
+	"github.com/spf13/cobra"
+)
+
+const (
+	redactDesc = `Read text from stdin and write it to stdout, masking any CodeCommit signed URL
+credentials, AWS access key IDs and AWS session tokens`
+
+	redactExs = `Prevent credentials from leaking into CI logs:
+
+$ git clone $(codecommit-sign codecommit::eu-west-1://repository) 2>&1 | codecommit-sign redact`
+)
+
+func newRedactCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "redact",
+		Short:   "Mask AWS credentials and signed CodeCommit URLs within text read from stdin",
+		Long:    redactDesc,
+		Example: redactExs,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return redact.Copy(out, cmd.InOrStdin())
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,52 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+type retryOptions struct {
+	MaxAttempts int
+	MaxBackoff  time.Duration
+}
+
+// Configures how the retrieval of credentials is retried by every command
+func (o retryOptions) install() error {
+	if o.MaxAttempts < 1 {
+		return errors.New("max attempts must be at least 1")
+	}
+
+	if o.MaxBackoff <= 0 {
+		return errors.New("max backoff must be greater than 0")
+	}
+
+	credentialsRetry.MaxAttempts = o.MaxAttempts
+	credentialsRetry.MaxBackoff = o.MaxBackoff
+	credentialsRetry.OnRetry = func(attempt int, delay time.Duration, err error) {
+		slog.Warn("retrying retrieval of aws credentials", "attempt", attempt, "delay", delay, "error", err)
+	}
+	return nil
+}
@@ -24,13 +24,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"strings"
+	"os"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/gembaadvantage/codecommit-sign/pkg/awsv4"
-	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+	codecommitsign "github.com/gembaadvantage/codecommit-sign"
+	"github.com/gembaadvantage/codecommit-sign/pkg/redact"
+	"github.com/gembaadvantage/codecommit-sign/pkg/retry"
 	"github.com/spf13/cobra"
 )
 
@@ -38,9 +39,9 @@ const (
 	desc = `Generate an AWS authenticated V4 signed CodeCommit URL that can be used to fetch
 and push changes to a CodeCommit repository within an AWS account.
 
-Both HTTPS and (git-remote-codecommit) GRC URL formats are supported. If a GRC
-URL is provided, it will automatically be translated into a compatible HTTPS
-URL`
+HTTPS, (git-remote-codecommit) GRC and SSH URL formats are supported, along with
+the URL of any repository page within the AWS console. If a non HTTPS URL is
+provided, it will automatically be translated into a compatible HTTPS URL`
 
 	exs = `Sign a CodeCommit HTTPS URL:
 
@@ -50,16 +51,28 @@ https://<USERNAME>:<PASSWORD>@git-codecommit.eu-west-1.amazonaws.com/v1/repos/re
 Sign a CodeCommit GRC URL:
 
 $ codecommit-sign codecommit::eu-west-1://repository
+https://<USERNAME>:<PASSWORD>@git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository
+
+Sign a CodeCommit SSH URL:
+
+$ codecommit-sign ssh://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository
 https://<USERNAME>:<PASSWORD>@git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository`
 )
 
 type signOptions struct {
-	Profile  string
-	CloneURL string
+	Profile          string
+	Region           string
+	InstanceMetadata bool
+	Verbose          bool
+	Debug            bool
+	CloneURL         string
 }
 
 func newRootCmd(out io.Writer, args []string) *cobra.Command {
 	opts := signOptions{}
+	logOpts := logOptions{}
+	retryOpts := retryOptions{}
+	credOpts := credentialOptions{}
 
 	cmd := &cobra.Command{
 		Use:          "codecommit-sign [URL]",
@@ -68,53 +81,103 @@ func newRootCmd(out io.Writer, args []string) *cobra.Command {
 		Example:      exs,
 		SilenceUsage: true,
 		Args:         cobra.ExactArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Logs are always written to stderr, ensuring they never corrupt a signed URL
+			if err := logOpts.install(os.Stderr); err != nil {
+				return err
+			}
+			if err := retryOpts.install(); err != nil {
+				return err
+			}
+			return credOpts.install(cmd.InOrStdin())
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts.CloneURL = args[0]
-			return opts.Run(out)
+
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+
+			// Ensure a signed URL can never be leaked through an error message
+			return redact.Error(opts.Run(ctx, out, cmd.ErrOrStderr()))
 		},
 	}
 
 	f := cmd.Flags()
-	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to use when looking up credentials")
-
-	cmd.AddCommand(newVersionCmd(out), newCompletionCmd(out), newManPagesCmd(out))
+	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to use when looking up credentials, overrides any profile within a GRC URL")
+	f.StringVar(&opts.Region, "region", "", "the AWS region to use if one cannot be identified from the URL")
+	f.BoolVar(&opts.InstanceMetadata, "instance-metadata", false, "resolve a region from EC2 or ECS metadata if all other sources fail")
+	f.BoolVarP(&opts.Verbose, "verbose", "v", false, "print details about how the URL was signed to stderr")
+	f.BoolVar(&opts.Debug, "debug", false, "print every step of signing the URL to stderr, including the canonical request")
+
+	pf := cmd.PersistentFlags()
+	pf.StringVar(&logOpts.Level, "log-level", "warn", "the minimum level of logs written to stderr, one of debug, info, warn or error")
+	pf.StringVar(&logOpts.Format, "log-format", "text", "the format of logs written to stderr, one of text or json")
+	pf.IntVar(&retryOpts.MaxAttempts, "max-attempts", retry.DefaultMaxAttempts, "the maximum number of attempts at retrieving AWS credentials when a transient error occurs")
+	pf.DurationVar(&retryOpts.MaxBackoff, "max-backoff", retry.DefaultMaxBackoff, "the maximum delay between attempts at retrieving AWS credentials")
+	pf.StringVar(&credOpts.AccessKeyID, "access-key-id", "", "an AWS access key id to use instead of the default credential chain")
+	pf.StringVar(&credOpts.SecretAccessKey, "secret-access-key", "", "an AWS secret access key, only accepted alongside --insecure")
+	pf.BoolVar(&credOpts.SecretAccessKeyStdin, "secret-access-key-stdin", false, "read an AWS secret access key from the first line of stdin")
+	pf.StringVar(&credOpts.SessionToken, "session-token", "", "an AWS session token, only accepted alongside --insecure")
+	pf.BoolVar(&credOpts.SessionTokenStdin, "session-token-stdin", false, "read an AWS session token from stdin, on the line after any secret access key")
+	pf.StringVar(&credOpts.File, "credentials-file", "", "a JSON file of credentials, in the credential_process format, to use instead of the default credential chain")
+	pf.BoolVar(&credOpts.Insecure, "insecure", false, "allow secrets to be provided as arguments")
+	pf.Duration("timeout", 0, "the maximum time a command can run for, including loading AWS credentials (e.g. 30s), disabled by default")
+
+	cmd.AddCommand(newVersionCmd(out),
+		newCompletionCmd(out),
+		newManPagesCmd(out),
+		newListCmd(out),
+		newCloneAllCmd(out),
+		newMirrorCmd(out),
+		newGitCmd(out),
+		newScrubCmd(out),
+		newRedactCmd(out),
+		newTranslateCmd(out),
+		newSubmodulesCmd(out),
+		newLoginCmd(out),
+		newCredentialProcessCmd(out),
+		newServeFakeCmd(out))
 	return cmd
 }
 
-func (o signOptions) Run(out io.Writer) error {
-	// Dynamically load options
-	opts := []func(*config.LoadOptions) error{}
-	if o.Profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(o.Profile))
+func (o signOptions) Run(ctx context.Context, out, errOut io.Writer) error {
+	var opts []codecommitsign.Option
+	if hook := o.debugHook(errOut); hook != nil {
+		opts = append(opts, codecommitsign.WithDebug(hook))
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(), opts...)
+	surl, err := newClient(o.Profile, o.Region, o.InstanceMetadata, opts...).SignURL(ctx, o.CloneURL)
 	if err != nil {
-		fmt.Fprintln(out, "\u26a0\ufe0f  failed to retrieve default AWS config")
+		var credsErr *codecommitsign.CredentialsError
+		if errors.As(err, &credsErr) {
+			if msg := ssoRemediation(ctx, credsErr.Profile); msg != "" {
+				fmt.Fprintln(out, msg)
+			} else {
+				fmt.Fprintln(out, "\u26a0\ufe0f  failed to retrieve AWS credentials")
+			}
+		}
 		return err
 	}
 
-	creds, err := cfg.Credentials.Retrieve(context.TODO())
-	if err != nil {
-		fmt.Fprintln(out, "\u26a0\ufe0f  failed to retrieve AWS credentials")
-		return err
-	}
+	fmt.Fprint(out, surl)
+	return nil
+}
 
-	signer := awsv4.NewSigner(creds)
-	// Detect if a GRC URL has been provided and translate
-	if strings.HasPrefix(o.CloneURL, "codecommit::") {
-		var terr error
-		o.CloneURL, terr = translate.FromGRC(o.CloneURL)
-		if terr != nil {
-			return terr
+// Generates a hook for tracing the signing process, depending on the level of detail requested.
+// The signer never exposes secrets or the signature, so they are not printed
+func (o signOptions) debugHook(errOut io.Writer) func(codecommitsign.Debug) {
+	switch {
+	case o.Debug:
+		return func(d codecommitsign.Debug) {
+			fmt.Fprint(errOut, d.String())
+		}
+	case o.Verbose:
+		return func(d codecommitsign.Debug) {
+			fmt.Fprintf(errOut, "region: %s (resolved from %s)\n", d.Region, d.RegionSource)
+			fmt.Fprintf(errOut, "credential scope: %s\n", d.CredentialScope)
+			fmt.Fprintf(errOut, "credential source: %s\n", d.CredentialSource)
 		}
 	}
 
-	surl, err := signer.Sign(o.CloneURL)
-	if err != nil {
-		return err
-	}
-
-	fmt.Fprint(out, surl)
 	return nil
 }
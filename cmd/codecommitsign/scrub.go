@@ -0,0 +1,106 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/scrub"
+	"github.com/spf13/cobra"
+)
+
+const (
+	scrubDesc = `Remove signed CodeCommit credentials from the remotes of a git repository.
+
+Any remote URL containing signed credentials, typically the result of cloning
+with a signed URL, is rewritten to its unsigned HTTPS form, or optionally its
+GRC form. By default only the repository within the current directory is
+scrubbed, providing --all will search the entire directory tree for repositories`
+
+	scrubExs = `Scrub the repository in the current directory:
+
+$ codecommit-sign scrub
+
+Scrub every repository beneath a directory, rewriting remotes to GRC URLs:
+
+$ codecommit-sign scrub --all --grc ~/projects`
+)
+
+type scrubOptions struct {
+	Dir string
+	All bool
+	GRC bool
+}
+
+func newScrubCmd(out io.Writer) *cobra.Command {
+	opts := scrubOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "scrub [DIR]",
+		Short:   "Remove signed CodeCommit credentials from existing git remotes",
+		Long:    scrubDesc,
+		Example: scrubExs,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Dir = "."
+			if len(args) > 0 {
+				opts.Dir = args[0]
+			}
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&opts.All, "all", false, "search the directory tree for repositories to scrub")
+	f.BoolVar(&opts.GRC, "grc", false, "rewrite remotes to GRC rather than HTTPS URLs")
+
+	return cmd
+}
+
+func (o scrubOptions) Run(ctx context.Context, out io.Writer) error {
+	var changes []scrub.Change
+	var err error
+
+	if o.All {
+		changes, err = scrub.All(ctx, o.Dir, o.GRC)
+	} else {
+		changes, err = scrub.Repo(ctx, o.Dir, o.GRC)
+	}
+
+	for _, c := range changes {
+		fmt.Fprintf(out, "%s: %s => %s\n", c.Repo, c.Key, c.URL)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "no signed remotes found")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/credproc"
+	"github.com/gembaadvantage/codecommit-sign/pkg/fakecodecommit"
+	"github.com/spf13/cobra"
+)
+
+const serveFakeExs = `Serve bare repositories from the current directory, accepting a single set of credentials:
+
+$ codecommit-sign serve-fake --region eu-west-1 --key-file credentials.json
+
+Clone through the fake server, by signing as normal and replacing the host:
+
+$ git clone $(codecommit-sign --credentials-file credentials.json codecommit::eu-west-1://repository | \
+    sed 's#https://\(.*\)@git-codecommit.eu-west-1.amazonaws.com#http://\1@127.0.0.1:8080#')`
+
+type serveFakeOptions struct {
+	Root       string
+	Addr       string
+	Region     string
+	KeyFiles   []string
+	MaxAge     time.Duration
+	Fault      string
+	FaultCount int
+}
+
+func newServeFakeCmd(out io.Writer) *cobra.Command {
+	opts := serveFakeOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "serve-fake [DIR]",
+		Short: "Serve local bare repositories through a fake CodeCommit git server",
+		Long: `Serve local bare repositories over git smart-HTTP through a fake CodeCommit git server,
+under /v1/repos/<name>. Every request must be authenticated with a signed URL, exactly
+as CodeCommit expects, allowing clone and push pipelines to be tested without AWS`,
+		Example: serveFakeExs,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Root = "."
+			if len(args) > 0 {
+				opts.Root = args[0]
+			}
+
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Addr, "addr", "127.0.0.1:8080", "the address to listen on")
+	f.StringVar(&opts.Region, "region", "us-east-1", "the AWS region requests must be signed for")
+	f.StringArrayVar(&opts.KeyFiles, "key-file", []string{}, "a JSON file of credentials, in the credential_process format, accepted by the server, can be repeated")
+	f.DurationVar(&opts.MaxAge, "max-age", fakecodecommit.DefaultMaxAge, "how long a signature remains valid for")
+	f.StringVar(&opts.Fault, "fault", "", "simulate a fault, one of expired-signature, wrong-region or throttle")
+	f.IntVar(&opts.FaultCount, "fault-count", 0, "only simulate a fault for the first number of requests, 0 for every request")
+
+	cmd.MarkFlagRequired("key-file")
+
+	return cmd
+}
+
+func (o serveFakeOptions) Run(ctx context.Context, out io.Writer) error {
+	keys := make([]fakecodecommit.Key, 0, len(o.KeyFiles))
+	for _, path := range o.KeyFiles {
+		key, err := readKey(path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	s := &fakecodecommit.Server{
+		Root:   o.Root,
+		Region: o.Region,
+		Keys:   keys,
+		MaxAge: o.MaxAge,
+		Logger: slog.Default(),
+	}
+
+	if o.Fault != "" {
+		fault := fakecodecommit.Fault(o.Fault)
+		if !slices.Contains(fakecodecommit.Faults, fault) {
+			return fmt.Errorf("unsupported fault %q", o.Fault)
+		}
+
+		s.Fault = fakecodecommit.Always(fault)
+		if o.FaultCount > 0 {
+			s.Fault = fakecodecommit.FirstN(o.FaultCount, fault)
+		}
+	}
+
+	l, err := net.Listen("tcp", o.Addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(out, "serving repositories from %s on http://%s/v1/repos/\n", o.Root, l.Addr())
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func readKey(path string) (fakecodecommit.Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fakecodecommit.Key{}, err
+	}
+	defer f.Close()
+
+	creds, err := credproc.Read(f)
+	if err != nil {
+		return fakecodecommit.Key{}, fmt.Errorf("reading credentials file %s: %w", path, err)
+	}
+
+	return fakecodecommit.Key{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}, nil
+}
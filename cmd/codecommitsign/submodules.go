@@ -0,0 +1,101 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/submodule"
+	"github.com/spf13/cobra"
+)
+
+const (
+	submodulesDesc = `Initialise and update the submodules of a repository, authenticating against
+every CodeCommit submodule with transient credentials.
+
+Both HTTPS and GRC submodule URLs are supported. Each submodule is signed using
+its own region and any named profile embedded within its GRC URL. Credentials
+are passed to git as transient config and are never written to .git/config`
+
+	submodulesExs = `Update all submodules within the current repository:
+
+$ codecommit-sign submodules
+
+Update all submodules, including any nested submodules:
+
+$ codecommit-sign submodules --recursive`
+)
+
+type submodulesOptions struct {
+	Profile          string
+	Region           string
+	InstanceMetadata bool
+	Recursive        bool
+	Dir              string
+}
+
+func newSubmodulesCmd(out io.Writer) *cobra.Command {
+	opts := submodulesOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "submodules [DIR]",
+		Short:   "Initialise and update submodules using transient CodeCommit credentials",
+		Long:    submodulesDesc,
+		Example: submodulesExs,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Dir = "."
+			if len(args) > 0 {
+				opts.Dir = args[0]
+			}
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.Profile, "profile", "", "the AWS named profile to use when looking up credentials, overrides any profile within a GRC URL")
+	f.StringVar(&opts.Region, "region", "", "the AWS region to use if one cannot be identified from a submodule URL")
+	f.BoolVar(&opts.InstanceMetadata, "instance-metadata", false, "resolve a region from EC2 or ECS metadata if all other sources fail")
+	f.BoolVar(&opts.Recursive, "recursive", false, "also update any nested submodules")
+
+	return cmd
+}
+
+func (o submodulesOptions) Run(ctx context.Context, out io.Writer) error {
+	client := newClient(o.Profile, o.Region, o.InstanceMetadata)
+	u := submodule.Updater{
+		Sign:      client.Sign,
+		Region:    client.Region,
+		Recursive: o.Recursive,
+	}
+
+	updated, err := u.Update(ctx, o.Dir)
+	for _, sub := range updated {
+		fmt.Fprintf(out, "%s: %s\n", sub.Path, sub.URL)
+	}
+	return err
+}
@@ -0,0 +1,174 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/gitauth"
+	"github.com/gembaadvantage/codecommit-sign/pkg/submodule"
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+	"github.com/spf13/cobra"
+)
+
+const (
+	translateDesc = `Translate a CodeCommit URL between the HTTPS, (git-remote-codecommit) GRC and
+SSH formats. Any supported URL format can be provided, including the URL of a
+repository page within the AWS console.
+
+Both the region and any named profile are preserved when translating to a GRC
+URL. HTTPS and SSH URLs require a region. If one cannot be identified from the
+URL, it is resolved in the same way as when signing, from the AWS_REGION and
+AWS_DEFAULT_REGION environment variables, followed by the shared AWS config file.
+Translation fails if no region can be resolved.
+
+Providing --gitmodules will instead translate the URL of every CodeCommit
+submodule within the .gitmodules file of a repository. Submodules hosted outside
+of CodeCommit are left untouched`
+
+	translateExs = `Translate an HTTPS URL into a GRC URL:
+
+$ codecommit-sign translate --to grc https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository
+codecommit::eu-west-1://repository
+
+Translate all CodeCommit submodules within the current repository to HTTPS URLs:
+
+$ codecommit-sign translate --to https --gitmodules`
+)
+
+type translateOptions struct {
+	To         string
+	Gitmodules bool
+	Arg        string
+}
+
+func newTranslateCmd(out io.Writer) *cobra.Command {
+	opts := translateOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "translate [URL|DIR]",
+		Short:   "Translate a CodeCommit URL between the HTTPS, GRC and SSH formats",
+		Long:    translateDesc,
+		Example: translateExs,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				opts.Arg = args[0]
+			}
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			return opts.Run(ctx, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&opts.To, "to", "", "the format to translate into, either grc, https or ssh")
+	f.BoolVar(&opts.Gitmodules, "gitmodules", false, "translate all CodeCommit submodules within a repository")
+
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func (o translateOptions) Run(ctx context.Context, out io.Writer) error {
+	form := translate.Form(o.To)
+	if form != translate.HTTPS && form != translate.GRC && form != translate.SSH {
+		return fmt.Errorf("unsupported url form %q", o.To)
+	}
+
+	region := newClient("", "", false).Region
+
+	if !o.Gitmodules {
+		if o.Arg == "" {
+			return errors.New("no url provided")
+		}
+
+		url, err := translateURL(ctx, o.Arg, form, region)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(out, url)
+		return nil
+	}
+
+	dir := o.Arg
+	if dir == "" {
+		dir = "."
+	}
+
+	subs, err := submodule.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		url, err := translateURL(ctx, sub.URL, form, region)
+		if errors.Is(err, translate.ErrNotCodeCommit) {
+			// Only CodeCommit submodules can be translated
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("translating submodule %s: %w", sub.Name, err)
+		}
+
+		if url == sub.URL {
+			continue
+		}
+
+		if err := submodule.SetURL(ctx, dir, sub.Name, url); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s: %s => %s\n", sub.Name, sub.URL, url)
+	}
+
+	return nil
+}
+
+// Translates a URL into the given form. Any missing region needed by an HTTPS or
+// SSH URL is resolved using the named profile within the URL
+func translateURL(ctx context.Context, url string, form translate.Form, region gitauth.RegionFunc) (string, error) {
+	if form == translate.GRC {
+		return translate.To(url, form, translate.WithLogger(slog.Default()))
+	}
+
+	rem, err := translate.Normalize(url, translate.WithLogger(slog.Default()))
+	if err != nil {
+		return "", err
+	}
+
+	if rem.Region == "" {
+		if rem.Region, err = region(ctx, rem.Profile); err != nil {
+			return "", err
+		}
+	}
+
+	if form == translate.SSH {
+		return translate.ToSSH(rem)
+	}
+	return translate.ToHTTPS(rem)
+}
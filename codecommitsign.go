@@ -0,0 +1,381 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package codecommitsign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/gembaadvantage/codecommit-sign/pkg/awsv4"
+	"github.com/gembaadvantage/codecommit-sign/pkg/region"
+	"github.com/gembaadvantage/codecommit-sign/pkg/retry"
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+)
+
+const (
+	// The window before credentials expire, in which they will be refreshed
+	credentialsExpiryWindow = 5 * time.Minute
+)
+
+// Client signs CodeCommit URLs on demand. Credentials are loaded once for each named
+// profile and cached until they near expiry, ensuring a long running process always
+// signs with valid credentials.
+//
+// A Client is safe for concurrent use by multiple goroutines
+type Client struct {
+	profile          string
+	region           string
+	instanceMetadata bool
+	credentials      aws.CredentialsProvider
+	retry            retry.Policy
+	logger           *slog.Logger
+	debug            func(Debug)
+
+	mu       sync.Mutex
+	profiles map[string]*profileSigner
+}
+
+// profileSigner pairs the cached credentials of a named profile with a signer
+// that uses them, and the policy for retrying their retrieval
+type profileSigner struct {
+	credentials *aws.CredentialsCache
+	signer      *awsv4.Signer
+	retry       retry.Policy
+	loaded      sync.Once
+}
+
+// CredentialsError is returned when AWS credentials cannot be loaded or retrieved
+// for signing a URL
+type CredentialsError struct {
+	// Profile is the AWS named profile credentials were loaded from, empty if the
+	// default profile or explicit credentials were used
+	Profile string
+
+	// Err is the underlying failure
+	Err error
+}
+
+func (e *CredentialsError) Error() string {
+	if e.Profile == "" {
+		return fmt.Sprintf("retrieving aws credentials: %s", e.Err)
+	}
+	return fmt.Sprintf("retrieving aws credentials for profile %q: %s", e.Profile, e.Err)
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithProfile sets the AWS named profile used when loading credentials, overriding
+// any profile embedded within a GRC URL
+func WithProfile(profile string) Option {
+	return func(c *Client) {
+		c.profile = profile
+	}
+}
+
+// WithRegion sets the AWS region used if one cannot be identified from a URL. It
+// takes precedence over the AWS_REGION and AWS_DEFAULT_REGION environment variables
+// and the region of any named profile
+func WithRegion(region string) Option {
+	return func(c *Client) {
+		c.region = region
+	}
+}
+
+// WithInstanceMetadata resolves a region from ECS task or EC2 instance metadata, if
+// one cannot be resolved from any other source
+func WithInstanceMetadata() Option {
+	return func(c *Client) {
+		c.instanceMetadata = true
+	}
+}
+
+// WithCredentials signs every URL using credentials retrieved from the provider,
+// bypassing the default AWS credential chain and any named profile
+func WithCredentials(provider aws.CredentialsProvider) Option {
+	return func(c *Client) {
+		c.credentials = provider
+	}
+}
+
+// WithRetry sets how transient failures are retried when retrieving credentials
+// from the default AWS credential chain. Up to maxAttempts attempts are made, with
+// a jittered exponential backoff between them of no more than maxBackoff. Values
+// less than 1 keep the defaults of 3 attempts and a 20 second backoff
+func WithRetry(maxAttempts int, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		if maxAttempts > 0 {
+			c.retry.MaxAttempts = maxAttempts
+		}
+		if maxBackoff > 0 {
+			c.retry.MaxBackoff = maxBackoff
+		}
+	}
+}
+
+// WithLogger records the normalization and signing of every URL through the logger.
+// Neither secrets nor signatures are ever logged. A nil logger is ignored
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// WithDebug registers a hook that is invoked with the intermediate steps each time a
+// URL is signed. The hook may be called concurrently if the Client is shared
+func WithDebug(hook func(Debug)) Option {
+	return func(c *Client) {
+		c.debug = hook
+	}
+}
+
+// New creates a new Client for signing CodeCommit URLs. No credentials are loaded
+// until the first URL is signed
+func New(opts ...Option) *Client {
+	c := &Client{
+		retry: retry.Policy{
+			MaxAttempts: retry.DefaultMaxAttempts,
+			MaxBackoff:  retry.DefaultMaxBackoff,
+		},
+		logger:   slog.New(slog.DiscardHandler),
+		profiles: map[string]*profileSigner{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.retry.OnRetry = func(attempt int, delay time.Duration, err error) {
+		c.logger.Warn("retrying retrieval of aws credentials", "attempt", attempt, "delay", delay, "error", err)
+	}
+	return c
+}
+
+// SignURL generates an AWS V4 signed CodeCommit HTTPS URL from any supported URL,
+// that can be used directly by git to clone, fetch or push. A *CredentialsError is
+// returned if credentials cannot be retrieved
+func (c *Client) SignURL(ctx context.Context, cloneURL string) (string, error) {
+	rem, err := translate.Normalize(cloneURL, translate.WithLogger(c.logger))
+	if err != nil {
+		return "", err
+	}
+
+	res := region.Resolution{Region: rem.Region, Source: "url"}
+	if rem.Region == "" {
+		if res, err = c.resolveRegion(ctx, rem.Profile); err != nil {
+			return "", err
+		}
+		rem.Region = res.Region
+	}
+	c.logger.DebugContext(ctx, "resolved aws region",
+		"repository", rem.Repository,
+		"region", res.Region,
+		"region_source", res.Source)
+
+	httpsURL, err := translate.ToHTTPS(rem)
+	if err != nil {
+		return "", err
+	}
+	return c.sign(ctx, httpsURL, rem.Profile, res.Source)
+}
+
+// Sign generates an AWS V4 signed CodeCommit HTTPS URL from an unsigned one, using
+// credentials from the named profile. An empty profile identifies the default
+// credentials. Any profile set through WithProfile takes precedence. A
+// *CredentialsError is returned if credentials cannot be retrieved
+func (c *Client) Sign(ctx context.Context, httpsURL, profile string) (string, error) {
+	return c.sign(ctx, httpsURL, profile, "url")
+}
+
+// Region resolves the AWS region used for the named profile, when one cannot be
+// identified from a URL. Any profile set through WithProfile takes precedence
+func (c *Client) Region(ctx context.Context, profile string) (string, error) {
+	res, err := c.resolveRegion(ctx, profile)
+	return res.Region, err
+}
+
+// Signs an HTTPS URL, recording where its region was resolved from for debugging
+func (c *Client) sign(ctx context.Context, httpsURL, profile, regionSource string) (string, error) {
+	profile = c.profileFor(profile)
+
+	// Explicit credentials are never tied to a profile
+	if c.credentials != nil {
+		profile = ""
+	}
+
+	ps, err := c.signer(ctx, profile)
+	if err != nil {
+		return "", &CredentialsError{Profile: profile, Err: err}
+	}
+
+	signer := ps.signer
+	if c.debug != nil {
+		// Debug details are specific to each URL, so need a dedicated signer
+		signer = awsv4.NewProviderSigner(ps.credentials,
+			awsv4.WithLogger(c.logger),
+			awsv4.WithDebug(func(d awsv4.Debug) {
+				c.debug(Debug{
+					URL:              d.URL,
+					Profile:          profile,
+					Region:           d.Region,
+					RegionSource:     regionSource,
+					Service:          d.Service,
+					RequestTime:      d.RequestTime,
+					CredentialScope:  d.CredentialScope,
+					CredentialSource: d.CredentialSource,
+					AccessKeyID:      d.AccessKeyID,
+					CanonicalRequest: d.CanonicalRequest,
+					StringToSign:     d.StringToSign,
+				})
+			}))
+	}
+
+	// Only transient failures to retrieve credentials are ever retried
+	var surl string
+	err = ps.retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		surl, err = signer.SignContext(ctx, httpsURL)
+		return err
+	})
+
+	var credsErr *awsv4.CredentialsError
+	if errors.As(err, &credsErr) {
+		return "", &CredentialsError{Profile: profile, Err: credsErr.Err}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ps.loaded.Do(func() {
+		c.logger.InfoContext(ctx, "retrieved aws credentials", "profile", profile)
+	})
+	return surl, nil
+}
+
+// The profile set through WithProfile takes precedence over any other profile
+func (c *Client) profileFor(profile string) string {
+	if c.profile != "" {
+		return c.profile
+	}
+	return profile
+}
+
+// Credentials generates the basic authentication username and password contained
+// within a signed CodeCommit URL, for git clients that accept credentials separately
+// from the URL
+func (c *Client) Credentials(ctx context.Context, cloneURL string) (string, string, error) {
+	surl, err := c.SignURL(ctx, cloneURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	u, err := url.Parse(surl)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing signed url: %w", err)
+	}
+
+	passw, _ := u.User.Password()
+	return u.User.Username(), passw, nil
+}
+
+// Retrieves the signer for a named profile, loading its credentials on first use
+func (c *Client) signer(ctx context.Context, profile string) (*profileSigner, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ps, ok := c.profiles[profile]; ok {
+		return ps, nil
+	}
+
+	provider := c.credentials
+	if provider == nil {
+		// The SDK always caches the credentials it resolves, so the expiry window must be
+		// applied to its cache, as any cache wrapping it would never see fresh credentials.
+		// The SSO and STS clients behind those credentials make a single attempt, leaving
+		// the retry policy as the only layer of retries
+		opts := []func(*config.LoadOptions) error{
+			config.WithCredentialsCacheOptions(withExpiryWindow),
+			config.WithRetryMaxAttempts(1),
+		}
+		if profile != "" {
+			opts = append(opts, config.WithSharedConfigProfile(profile))
+		}
+
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		provider = cfg.Credentials
+	}
+
+	cache, ok := provider.(*aws.CredentialsCache)
+	if !ok {
+		cache = aws.NewCredentialsCache(provider, withExpiryWindow)
+	}
+
+	ps := &profileSigner{
+		credentials: cache,
+		signer:      awsv4.NewProviderSigner(cache, awsv4.WithLogger(c.logger)),
+		retry:       c.retry,
+	}
+
+	// Only the default credential chain is retried
+	if c.credentials != nil {
+		ps.retry.MaxAttempts = 1
+	}
+	c.profiles[profile] = ps
+	return ps, nil
+}
+
+// Refreshes cached credentials before they come within the expiry window
+func withExpiryWindow(o *aws.CredentialsCacheOptions) {
+	o.ExpiryWindow = credentialsExpiryWindow
+}
+
+// Resolves a region for a profile through the chain of configured sources
+func (c *Client) resolveRegion(ctx context.Context, profile string) (region.Resolution, error) {
+	chain := region.Chain{
+		region.Static{Value: c.region, Name: "region option"},
+		region.Env("AWS_REGION"),
+		region.Env("AWS_DEFAULT_REGION"),
+		region.SharedConfig{Profile: c.profileFor(profile)},
+	}
+
+	if c.instanceMetadata {
+		chain = append(chain, region.ECS{}, region.IMDS{})
+	}
+	return chain.Resolve(ctx)
+}
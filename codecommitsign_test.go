@@ -0,0 +1,380 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package codecommitsign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var passwordRgx = regexp.MustCompile(`^\d{8}T\d{6}Z[0-9a-f]{64}$`)
+
+func staticCredentials() aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID:     "ACCESS_KEY_ID",
+			SecretAccessKey: "SECRET_ACCESS_KEY",
+			SessionToken:    "a/b+c==",
+		}, nil
+	})
+}
+
+// Isolates a test from any region or profile configured within the environment
+func isolate(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_PROFILE", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"} {
+		t.Setenv(env, "")
+	}
+	return dir
+}
+
+func parse(t *testing.T, surl string) *url.URL {
+	t.Helper()
+
+	u, err := url.Parse(surl)
+	require.NoError(t, err)
+	return u
+}
+
+func TestSignURL(t *testing.T) {
+	isolate(t)
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{
+			name: "HTTPS",
+			url:  "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name: "GRC",
+			url:  "codecommit::eu-west-1://repository",
+		},
+		{
+			name: "SSH",
+			url:  "ssh://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name: "Console",
+			url:  "https://eu-west-1.console.aws.amazon.com/codesuite/codecommit/repositories/repository/browse?region=eu-west-1",
+		},
+	}
+
+	c := New(WithCredentials(staticCredentials()))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			surl, err := c.SignURL(context.Background(), tt.url)
+			require.NoError(t, err)
+
+			u := parse(t, surl)
+			assert.Equal(t, "git-codecommit.eu-west-1.amazonaws.com", u.Host)
+			assert.Equal(t, "/v1/repos/repository", u.Path)
+			assert.Equal(t, "ACCESS_KEY_ID%a/b+c==", u.User.Username())
+		})
+	}
+}
+
+func TestSignURL_Unsupported(t *testing.T) {
+	_, err := New(WithCredentials(staticCredentials())).SignURL(context.Background(), "https://github.com/org/repository")
+	require.Error(t, err)
+}
+
+func TestSignURL_RegionOption(t *testing.T) {
+	isolate(t)
+	t.Setenv("AWS_REGION", "eu-west-2")
+
+	c := New(WithCredentials(staticCredentials()), WithRegion("us-east-1"))
+
+	surl, err := c.SignURL(context.Background(), "codecommit://repository")
+	require.NoError(t, err)
+	assert.Equal(t, "git-codecommit.us-east-1.amazonaws.com", parse(t, surl).Host)
+}
+
+func TestSignURL_RegionFromEnv(t *testing.T) {
+	isolate(t)
+	t.Setenv("AWS_DEFAULT_REGION", "ap-southeast-2")
+
+	surl, err := New(WithCredentials(staticCredentials())).SignURL(context.Background(), "codecommit://repository")
+	require.NoError(t, err)
+	assert.Equal(t, "git-codecommit.ap-southeast-2.amazonaws.com", parse(t, surl).Host)
+}
+
+func TestSignURL_NoRegion(t *testing.T) {
+	isolate(t)
+
+	_, err := New(WithCredentials(staticCredentials())).SignURL(context.Background(), "codecommit://repository")
+	require.EqualError(t, err, "no aws region identified")
+}
+
+func TestSignURL_Profile(t *testing.T) {
+	dir := isolate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte(`[profile embedded]
+region = eu-west-2
+
+[profile override]
+region = us-west-2
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "credentials"), []byte(`[embedded]
+aws_access_key_id = EMBEDDED_ACCESS_KEY_ID
+aws_secret_access_key = SECRET_ACCESS_KEY
+
+[override]
+aws_access_key_id = OVERRIDE_ACCESS_KEY_ID
+aws_secret_access_key = SECRET_ACCESS_KEY
+`), 0o600))
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		host   string
+		access string
+	}{
+		{
+			name:   "Embedded",
+			host:   "git-codecommit.eu-west-2.amazonaws.com",
+			access: "EMBEDDED_ACCESS_KEY_ID%",
+		},
+		{
+			name:   "Override",
+			opts:   []Option{WithProfile("override")},
+			host:   "git-codecommit.us-west-2.amazonaws.com",
+			access: "OVERRIDE_ACCESS_KEY_ID%",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			surl, err := New(tt.opts...).SignURL(context.Background(), "codecommit://embedded@repository")
+			require.NoError(t, err)
+
+			u := parse(t, surl)
+			assert.Equal(t, tt.host, u.Host)
+			assert.Equal(t, tt.access, u.User.Username())
+		})
+	}
+}
+
+func TestSignURL_CredentialsError(t *testing.T) {
+	c := New(WithCredentials(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.New("no credentials")
+	})))
+
+	_, err := c.SignURL(context.Background(), "codecommit::eu-west-1://profile@repository")
+	require.ErrorContains(t, err, "no credentials")
+
+	var credsErr *CredentialsError
+	require.ErrorAs(t, err, &credsErr)
+	assert.Empty(t, credsErr.Profile)
+}
+
+func TestSignURL_ProfileCredentialsError(t *testing.T) {
+	isolate(t)
+
+	_, err := New().SignURL(context.Background(), "codecommit::eu-west-1://missing@repository")
+
+	var credsErr *CredentialsError
+	require.ErrorAs(t, err, &credsErr)
+	assert.Equal(t, "missing", credsErr.Profile)
+}
+
+func TestSignURL_CachesCredentials(t *testing.T) {
+	var calls int
+	c := New(WithCredentials(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		calls++
+		return aws.Credentials{AccessKeyID: "ACCESS_KEY_ID", SecretAccessKey: "SECRET_ACCESS_KEY"}, nil
+	})))
+
+	for i := 0; i < 3; i++ {
+		_, err := c.SignURL(context.Background(), "codecommit::eu-west-1://repository")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+// Credentials that expire within the expiry window must be refreshed on every
+// signing, even when resolved and cached by the SDK
+func TestSignURL_RefreshesExpiringCredentials(t *testing.T) {
+	dir := isolate(t)
+
+	creds := fmt.Sprintf(`{"Version":1,"AccessKeyId":"ACCESS_KEY_ID","SecretAccessKey":"SECRET_ACCESS_KEY","Expiration":%q}`,
+		time.Now().Add(3*time.Minute).UTC().Format(time.RFC3339))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "creds.json"), []byte(creds), 0o600))
+
+	calls := filepath.Join(dir, "calls")
+	script := filepath.Join(dir, "credential-process.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho >> "+calls+"\ncat "+filepath.Join(dir, "creds.json")+"\n"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte("[default]\ncredential_process = "+script+"\n"), 0o600))
+
+	c := New()
+	for i := 0; i < 3; i++ {
+		_, err := c.SignURL(context.Background(), "codecommit::eu-west-1://repository")
+		require.NoError(t, err)
+	}
+
+	out, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(out), "\n"))
+}
+
+func TestSignURL_RefreshesExpiringExplicitCredentials(t *testing.T) {
+	var calls int
+	c := New(WithCredentials(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		calls++
+		return aws.Credentials{
+			AccessKeyID:     "ACCESS_KEY_ID",
+			SecretAccessKey: "SECRET_ACCESS_KEY",
+			CanExpire:       true,
+			Expires:         time.Now().Add(3 * time.Minute),
+		}, nil
+	})))
+
+	for i := 0; i < 3; i++ {
+		_, err := c.SignURL(context.Background(), "codecommit::eu-west-1://repository")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, calls)
+}
+
+func TestCredentials(t *testing.T) {
+	user, passw, err := New(WithCredentials(staticCredentials())).
+		Credentials(context.Background(), "codecommit::eu-west-1://repository")
+	require.NoError(t, err)
+
+	assert.Equal(t, "ACCESS_KEY_ID%a/b+c==", user)
+	assert.Regexp(t, passwordRgx, passw)
+}
+
+func TestSignURL_Debug(t *testing.T) {
+	isolate(t)
+	t.Setenv("AWS_REGION", "eu-west-2")
+
+	var debug Debug
+	c := New(WithCredentials(staticCredentials()), WithDebug(func(d Debug) { debug = d }))
+
+	_, err := c.SignURL(context.Background(), "codecommit://repository")
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/repository", debug.URL)
+	assert.Equal(t, "eu-west-2", debug.Region)
+	assert.Equal(t, "AWS_REGION", debug.RegionSource)
+	assert.Equal(t, "codecommit", debug.Service)
+	assert.Equal(t, "ACCESS_KEY_ID", debug.AccessKeyID)
+	assert.Contains(t, debug.String(), "region: eu-west-2 (resolved from AWS_REGION)\n")
+}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		maxBackoff  time.Duration
+		attempts    int
+		backoff     time.Duration
+	}{
+		{
+			name:        "Custom",
+			maxAttempts: 5,
+			maxBackoff:  time.Second,
+			attempts:    5,
+			backoff:     time.Second,
+		},
+		{
+			name:     "Defaults",
+			attempts: 3,
+			backoff:  20 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(WithRetry(tt.maxAttempts, tt.maxBackoff))
+
+			assert.Equal(t, tt.attempts, c.retry.MaxAttempts)
+			assert.Equal(t, tt.backoff, c.retry.MaxBackoff)
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	surl, err := New(WithCredentials(staticCredentials())).
+		Sign(context.Background(), "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository", "")
+	require.NoError(t, err)
+
+	u := parse(t, surl)
+	assert.Equal(t, "git-codecommit.eu-west-1.amazonaws.com", u.Host)
+	assert.Equal(t, "ACCESS_KEY_ID%a/b+c==", u.User.Username())
+}
+
+func TestRegion(t *testing.T) {
+	dir := isolate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte(`[profile embedded]
+region = eu-west-2
+
+[profile override]
+region = us-west-2
+`), 0o600))
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		region string
+	}{
+		{
+			name:   "Profile",
+			region: "eu-west-2",
+		},
+		{
+			name:   "ProfileOverride",
+			opts:   []Option{WithProfile("override")},
+			region: "us-west-2",
+		},
+		{
+			name:   "RegionOption",
+			opts:   []Option{WithRegion("ap-south-1")},
+			region: "ap-south-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region, err := New(tt.opts...).Region(context.Background(), "embedded")
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.region, region)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package codecommitsign generates AWS V4 signed CodeCommit URLs, and the basic
+authentication credentials within them, for use by git clients written in Go.
+
+A Client bundles everything the codecommit-sign CLI does when signing a URL.
+HTTPS, GRC (git-remote-codecommit), SSH and AWS console URLs are normalized into
+an HTTPS clone URL, a region is resolved if the URL does not contain one, and
+credentials are loaded from the default AWS credential chain before signing:
+
+	client := codecommitsign.New(codecommitsign.WithRegion("eu-west-1"))
+
+	surl, err := client.SignURL(ctx, "codecommit://profile@repository")
+
+Any named profile embedded within a GRC URL is used when loading credentials,
+unless overridden with WithProfile.
+
+# Compatibility
+
+This package follows semantic versioning. Within a major version, exported
+identifiers will not be removed or renamed, function and method signatures will
+not change, and the format of a signed URL will remain compatible with
+CodeCommit. New options may be added within a minor version. Packages beneath
+pkg/ are used to build this package and the CLI, and may change between minor
+versions, while packages beneath internal/ can never be imported. The API of this
+package only exposes its own types and those of the AWS SDK, so changes beneath
+pkg/ never break it.
+*/
+package codecommitsign
@@ -0,0 +1,243 @@
+//go:build integration
+
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/gembaadvantage/codecommit-sign/internal/gittest"
+	"github.com/gembaadvantage/codecommit-sign/pkg/awsv4"
+	"github.com/gembaadvantage/codecommit-sign/pkg/fakecodecommit"
+	"github.com/stretchr/testify/require"
+)
+
+// The region hosting the fake CodeCommit server
+const region = "eu-west-1"
+
+// Regions a TLS certificate is issued for, allowing requests for other regions to
+// reach the fake server and be rejected
+var regions = []string{region, "us-east-1"}
+
+// harness runs a fake CodeCommit server behind a TLS terminating proxy. Git is
+// configured to tunnel through the proxy and trust its certificate, allowing
+// unmodified signed CodeCommit URLs to be used with the real git binary
+type harness struct {
+	server *fakecodecommit.Server
+	root   string
+}
+
+// newHarness starts a fake CodeCommit server that accepts the given keys, and
+// configures git to route every CodeCommit request to it through global config
+func newHarness(t *testing.T, keys ...fakecodecommit.Key) *harness {
+	t.Helper()
+
+	h := &harness{
+		server: &fakecodecommit.Server{
+			Root:   t.TempDir(),
+			Region: region,
+			Keys:   keys,
+		},
+	}
+	h.root = h.server.Root
+
+	cert, caFile := issueCertificate(t)
+	proxy := httptest.NewServer(&tlsProxy{
+		handler: h.server,
+		config:  &tls.Config{Certificates: []tls.Certificate{cert}},
+	})
+	t.Cleanup(proxy.Close)
+
+	home := t.TempDir()
+	gitconfig := filepath.Join(home, ".gitconfig")
+	require.NoError(t, os.WriteFile(gitconfig, []byte(fmt.Sprintf(`[user]
+	name = codecommit-sign
+	email = codecommit-sign@example.com
+[http]
+	proxy = %s
+	sslCAInfo = %s
+[protocol "file"]
+	allow = always
+`, proxy.URL, caFile)), 0o600))
+
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_SSL_CAINFO", caFile)
+	t.Setenv("GIT_TERMINAL_PROMPT", "0")
+	for _, env := range []string{"NO_PROXY", "no_proxy", "HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy"} {
+		t.Setenv(env, "")
+	}
+
+	return h
+}
+
+// repo creates a bare repository on the fake server, containing a single commit
+func (h *harness) repo(t *testing.T, name string) {
+	t.Helper()
+	gittest.BareRepo(t, filepath.Join(h.root, name+".git"))
+}
+
+// url returns the unsigned CodeCommit HTTPS URL of a repository
+func url(name string) string {
+	return fmt.Sprintf("https://git-codecommit.%s.amazonaws.com/v1/repos/%s", region, name)
+}
+
+// sign generates a signed CodeCommit URL for a repository
+func sign(t *testing.T, creds aws.Credentials, url string) string {
+	t.Helper()
+
+	surl, err := awsv4.NewSigner(creds).SignContext(context.Background(), url)
+	require.NoError(t, err)
+	return surl
+}
+
+// Issues a certificate for the CodeCommit hosts of every region, signed by a
+// throwaway certificate authority that is written to a PEM encoded file for git
+// to trust
+func issueCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "codecommit-sign e2e"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	hosts := make([]string, 0, len(regions))
+	for _, r := range regions {
+		hosts = append(hosts, fmt.Sprintf("git-codecommit.%s.amazonaws.com", r))
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600))
+
+	return tls.Certificate{Certificate: [][]byte{der, caDER}, PrivateKey: key}, caFile
+}
+
+// tlsProxy accepts CONNECT requests from git, terminating the tunnelled TLS
+// connection itself and serving it directly with the handler
+type tlsProxy struct {
+	handler http.Handler
+	config  *tls.Config
+}
+
+func (p *tlsProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		conn.Close()
+		return
+	}
+
+	tunnel := tls.Server(&bufferedConn{Conn: conn, r: buf.Reader}, p.config)
+	srv := &http.Server{Handler: p.handler}
+	srv.Serve(&singleListener{conn: tunnel})
+}
+
+// bufferedConn reads any bytes already buffered when the connection was hijacked
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// singleListener accepts a single connection, before reporting itself as closed
+type singleListener struct {
+	conn net.Conn
+}
+
+func (l *singleListener) Accept() (net.Conn, error) {
+	if l.conn == nil {
+		return nil, net.ErrClosed
+	}
+
+	c := l.conn
+	l.conn = nil
+	return c, nil
+}
+
+func (l *singleListener) Close() error {
+	return nil
+}
+
+func (l *singleListener) Addr() net.Addr {
+	return &net.TCPAddr{}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package gittest provides helpers for creating local git repositories within tests
+package gittest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Git runs a git command within the given directory, failing the test if the
+// command cannot be executed. The trimmed output of the command is returned
+func Git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=codecommit-sign",
+		"GIT_AUTHOR_EMAIL=codecommit-sign@example.com",
+		"GIT_COMMITTER_NAME=codecommit-sign",
+		"GIT_COMMITTER_EMAIL=codecommit-sign@example.com",
+		"GIT_CONFIG_NOSYSTEM=1",
+	)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// Repo initialises a new git repository containing a single commit on the main
+// branch. The path to the repository is returned
+func Repo(t *testing.T, dir string) string {
+	t.Helper()
+
+	Git(t, "", "init", "--quiet", "--initial-branch", "main", dir)
+	Commit(t, dir, "initial commit")
+
+	return dir
+}
+
+// BareRepo initialises a new bare git repository containing a single commit on the
+// main branch. The path to the repository is returned
+func BareRepo(t *testing.T, dir string) string {
+	t.Helper()
+
+	src := Repo(t, filepath.Join(t.TempDir(), "src"))
+	Git(t, "", "clone", "--quiet", "--bare", src, dir)
+
+	return dir
+}
+
+// Commit creates a new empty commit within the repository, returning its hash
+func Commit(t *testing.T, dir, msg string) string {
+	t.Helper()
+
+	Git(t, dir, "commit", "--quiet", "--allow-empty", "-m", msg)
+	return Git(t, dir, "rev-parse", "HEAD")
+}
@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package awsv4
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Debug captures the intermediate steps of signing a CodeCommit URL, making it possible
+// to compare them against what AWS expects when a signed URL is rejected. Neither the
+// secret access key, session token nor the generated signature are ever captured
+type Debug struct {
+	// URL is the unsigned CodeCommit URL
+	URL string
+
+	// Region is the AWS region identified from the URL
+	Region string
+
+	// Service is the AWS service the request is scoped to
+	Service string
+
+	// RequestTime is the time used when generating the signature
+	RequestTime time.Time
+
+	// CredentialScope is the scope of the signature, <date>/<region>/<service>/aws4_request
+	CredentialScope string
+
+	// CredentialSource identifies the provider that supplied the credentials
+	CredentialSource string
+
+	// AccessKeyID is the access key ID of the credentials used to sign the URL
+	AccessKeyID string
+
+	// CanonicalRequest is the canonical request used to generate the signature
+	CanonicalRequest string
+
+	// StringToSign is the string to sign used to generate the signature
+	StringToSign string
+}
+
+// String formats the debug information over multiple lines
+func (d Debug) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "url: %s\n", d.URL)
+	fmt.Fprintf(&b, "region: %s\n", d.Region)
+	fmt.Fprintf(&b, "service: %s\n", d.Service)
+	fmt.Fprintf(&b, "request time: %s\n", d.RequestTime.Format("20060102T150405Z"))
+	fmt.Fprintf(&b, "credential scope: %s\n", d.CredentialScope)
+	fmt.Fprintf(&b, "credential source: %s\n", d.CredentialSource)
+	fmt.Fprintf(&b, "access key id: %s\n", d.AccessKeyID)
+	fmt.Fprintf(&b, "canonical request:\n%s\n", indent(d.CanonicalRequest))
+	fmt.Fprintf(&b, "string to sign:\n%s\n", indent(d.StringToSign))
+
+	return b.String()
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
+
+// Option customises the behaviour of a Signer
+type Option func(*Signer)
+
+// WithDebug registers a hook that is invoked with the intermediate steps each time a
+// URL is signed. The hook may be called concurrently if the Signer is shared
+func WithDebug(hook func(Debug)) Option {
+	return func(s *Signer) {
+		s.debug = hook
+	}
+}
+
+// WithLogger sets the logger used to record each signing request. By default all logs
+// are discarded. Secrets and signatures are never logged
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Signer) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package awsv4
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDebug(t *testing.T) {
+	var dbg Debug
+	s := NewSigner(aws.Credentials{
+		AccessKeyID:     "ACCESS_KEY_ID",
+		SecretAccessKey: "SECRET_ACCESS_KEY",
+		SessionToken:    "SESSION_TOKEN",
+		Source:          "StaticCredentials",
+	}, WithDebug(func(d Debug) {
+		dbg = d
+	}))
+
+	surl, err := s.Sign(repoURL)
+	require.NoError(t, err)
+
+	assert.Equal(t, repoURL, dbg.URL)
+	assert.Equal(t, "eu-west-1", dbg.Region)
+	assert.Equal(t, "codecommit", dbg.Service)
+	assert.Equal(t, dbg.RequestTime.Format("20060102")+"/eu-west-1/codecommit/aws4_request", dbg.CredentialScope)
+	assert.Equal(t, "StaticCredentials", dbg.CredentialSource)
+	assert.Equal(t, "ACCESS_KEY_ID", dbg.AccessKeyID)
+	assert.Equal(t, "GIT\n/v1/repos/dummy-repo\n\nhost:git-codecommit.eu-west-1.amazonaws.com\n\nhost\n", dbg.CanonicalRequest)
+	assert.True(t, strings.HasPrefix(dbg.StringToSign, "AWS4-HMAC-SHA256\n"+dbg.RequestTime.Format("20060102T150405")))
+
+	// Neither secrets nor the signature should be captured
+	sig := surl[strings.Index(surl, "Z")+1 : strings.Index(surl, "@")]
+	out := dbg.String()
+	assert.NotContains(t, out, "SECRET_ACCESS_KEY")
+	assert.NotContains(t, out, "SESSION_TOKEN")
+	assert.NotContains(t, out, sig)
+}
+
+func TestDebug_String(t *testing.T) {
+	d := Debug{
+		URL:              repoURL,
+		Region:           "eu-west-1",
+		Service:          "codecommit",
+		RequestTime:      time.Date(2021, 9, 1, 10, 25, 23, 0, time.UTC),
+		CredentialScope:  "20210901/eu-west-1/codecommit/aws4_request",
+		CredentialSource: "SharedConfigCredentials",
+		AccessKeyID:      "ACCESS_KEY_ID",
+		CanonicalRequest: "GIT\n/v1/repos/dummy-repo",
+		StringToSign:     "AWS4-HMAC-SHA256\n20210901T102523",
+	}
+
+	assert.Equal(t, `url: https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/dummy-repo
+region: eu-west-1
+service: codecommit
+request time: 20210901T102523Z
+credential scope: 20210901/eu-west-1/codecommit/aws4_request
+credential source: SharedConfigCredentials
+access key id: ACCESS_KEY_ID
+canonical request:
+  GIT
+  /v1/repos/dummy-repo
+string to sign:
+  AWS4-HMAC-SHA256
+  20210901T102523
+`, d.String())
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSigner(aws.Credentials{
+		AccessKeyID:     "ACCESS_KEY_ID",
+		SecretAccessKey: "SECRET_ACCESS_KEY",
+		SessionToken:    "SESSION_TOKEN",
+		Source:          "StaticCredentials",
+	}, WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+	surl, err := s.Sign(repoURL)
+	require.NoError(t, err)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "signed codecommit url", entry["msg"])
+	assert.Equal(t, "eu-west-1", entry["region"])
+	assert.Equal(t, "dummy-repo", entry["repository"])
+	assert.Equal(t, "StaticCredentials", entry["credential_source"])
+
+	sig := surl[strings.Index(surl, "Z")+1 : strings.Index(surl, "@")]
+	assert.NotContains(t, buf.String(), "SECRET_ACCESS_KEY")
+	assert.NotContains(t, buf.String(), "SESSION_TOKEN")
+	assert.NotContains(t, buf.String(), sig)
+}
+
+func TestWithLogger_Nil(t *testing.T) {
+	s := NewSigner(aws.Credentials{AccessKeyID: "ACCESS_KEY_ID"}, WithLogger(nil))
+
+	_, err := s.Sign(repoURL)
+	require.NoError(t, err)
+}
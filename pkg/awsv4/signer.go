@@ -24,40 +24,120 @@ package awsv4
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 )
 
 var (
-	urlRgx = regexp.MustCompile(`^https://git-codecommit\.(.*)\.(amazonaws\.com|amazonaws\.com\.cn)/v1/repos/.*$`)
+	urlRgx = regexp.MustCompile(`^https://git-codecommit(?:-fips)?\.([a-z0-9-]+)\.(?:amazonaws\.com|amazonaws\.com\.cn)/v1/repos/.*$`)
 )
 
 // Signer implements the AWS authenticated V4 Signature Specification for
 // generating authenticated requests to CodeCommit repositories.  The signature is
 // generated using the V4 Signature Specification, see:
 // https://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html
+//
+// A Signer holds no per-request state and is safe for concurrent use by multiple
+// goroutines, regardless of the region being targeted
 type Signer struct {
 	service     string
+	credentials aws.CredentialsProvider
+	keys        derivedKeyCache
+	debug       func(Debug)
+	logger      *slog.Logger
+}
+
+// scope captures the state of an individual signing request. It is generated
+// on every call to sign a URL and never shared between requests
+type scope struct {
 	region      string
-	credentials aws.Credentials
 	requestTime time.Time
+	credentials aws.Credentials
+}
+
+// derivedKeyCache stores signing keys derived from a secret access key, removing the
+// need to perform the four chained HMAC operations each time a URL is signed. Keys are
+// scoped to a single date and secret, and are discarded as soon as either changes
+type derivedKeyCache struct {
+	mu     sync.RWMutex
+	date   string
+	secret string
+	keys   map[string][]byte
 }
 
-// NewSigner creates a new V4 signer for signing CodeCommit URLs
-func NewSigner(creds aws.Credentials) *Signer {
-	return &Signer{
+// CredentialsError is returned when a Signer cannot retrieve credentials from its
+// provider, distinguishing it from a malformed URL
+type CredentialsError struct {
+	Err error
+}
+
+func (e *CredentialsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+const (
+	// The window before credentials expire, in which they will be refreshed. Ensures
+	// a signed URL isn't generated from a session token on the verge of expiring
+	credentialsExpiryWindow = 5 * time.Minute
+)
+
+// NewSigner creates a new V4 signer for signing CodeCommit URLs using a fixed set of
+// credentials that will never be refreshed
+func NewSigner(creds aws.Credentials, opts ...Option) *Signer {
+	s := &Signer{
+		service: "codecommit",
+		credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return creds, nil
+		}),
+		logger: slog.New(slog.DiscardHandler),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewProviderSigner creates a new V4 signer for signing CodeCommit URLs. Credentials
+// are retrieved from the provider each time a URL is signed, and are cached until they
+// near expiry, ensuring long running processes always sign with valid credentials.
+//
+// A provider that is already an aws.CredentialsCache is used as is, as wrapping it
+// would never see fresh credentials. Credentials loaded through the AWS config should
+// set an expiry window with config.WithCredentialsCacheOptions
+func NewProviderSigner(provider aws.CredentialsProvider, opts ...Option) *Signer {
+	if _, ok := provider.(*aws.CredentialsCache); !ok {
+		provider = aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) {
+			o.ExpiryWindow = credentialsExpiryWindow
+		})
+	}
+
+	s := &Signer{
 		service:     "codecommit",
-		credentials: creds,
+		credentials: provider,
+		logger:      slog.New(slog.DiscardHandler),
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Sign will sign a CodeCommit clone URL using the AWS authenticated V4 Signature
@@ -68,26 +148,77 @@ func NewSigner(creds aws.Credentials) *Signer {
 // credentials and supports authentication directly from an IAM role within services such
 // as AWS Lambda and AWS CodeBuild
 func (s *Signer) Sign(cloneURL string) (string, error) {
-	var err error
-	if s.region, err = identifyRegion(cloneURL); err != nil {
+	return s.SignContext(context.Background(), cloneURL)
+}
+
+// SignContext will sign a CodeCommit clone URL in the same way as Sign, but will
+// abort signing if the provided context is cancelled
+func (s *Signer) SignContext(ctx context.Context, cloneURL string) (string, error) {
+	uname, passw, err := s.credentialsFor(ctx, cloneURL)
+	if err != nil {
 		return "", err
 	}
 
-	s.requestTime = time.Now().UTC()
+	return strings.Replace(cloneURL, "https://", fmt.Sprintf("https://%s:%s@", url.QueryEscape(uname), passw), 1), nil
+}
+
+// Generates the basic authentication username and password for a CodeCommit clone URL.
+// The username is returned unescaped, exactly as it should be sent to CodeCommit
+func (s *Signer) credentialsFor(ctx context.Context, cloneURL string) (string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+
+	region, err := identifyRegion(cloneURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		s.logger.DebugContext(ctx, "failed to retrieve aws credentials", "region", region, "error", err)
+		return "", "", &CredentialsError{Err: err}
+	}
+
+	sc := scope{
+		region:      region,
+		requestTime: time.Now().UTC(),
+		credentials: creds,
+	}
 
 	// Perform all 4 tasks in order to ensure a V4 signature matching the specification is generated
-	req, _ := http.NewRequest("GIT", cloneURL, http.NoBody)
+	req, _ := http.NewRequestWithContext(ctx, "GIT", cloneURL, http.NoBody)
 
 	cr := s.canonicalRequest(req)
-	sts := s.stringToSign(cr)
-	sig := s.signature(sts)
+	sts := s.stringToSign(sc, cr)
+	sig := s.signature(sc, sts)
+
+	s.logger.DebugContext(ctx, "signed codecommit url",
+		"region", sc.region,
+		"repository", strings.TrimPrefix(req.URL.Path, "/v1/repos/"),
+		"credential_source", creds.Source,
+		"request_time", sc.requestTime.Format("20060102T150405Z"))
+
+	if s.debug != nil {
+		s.debug(Debug{
+			URL:              cloneURL,
+			Region:           sc.region,
+			Service:          s.service,
+			RequestTime:      sc.requestTime,
+			CredentialScope:  fmt.Sprintf("%s/%s/%s/aws4_request", sc.requestTime.Format("20060102"), sc.region, s.service),
+			CredentialSource: creds.Source,
+			AccessKeyID:      creds.AccessKeyID,
+			CanonicalRequest: string(cr),
+			StringToSign:     string(sts),
+		})
+	}
 
-	// Reconstruct and return the CodeCommit signed URL. Inspiration taken directly from:
+	// Construct the CodeCommit credentials. Inspiration taken directly from:
 	// https://github.com/aws/git-remote-codecommit/blob/c696b4977761ea5b0c0e385da69a0bd09034b566/git_remote_codecommit/__init__.py#L214
-	passw := fmt.Sprintf("%sZ%s", s.requestTime.Format("20060102T150405"), fmt.Sprintf("%x", sig))
-	uname := url.QueryEscape(s.credentials.AccessKeyID + "%" + s.credentials.SessionToken)
+	passw := fmt.Sprintf("%sZ%s", sc.requestTime.Format("20060102T150405"), fmt.Sprintf("%x", sig))
+	uname := sc.credentials.AccessKeyID + "%" + sc.credentials.SessionToken
 
-	return strings.Replace(cloneURL, "https://", fmt.Sprintf("https://%s:%s@", uname, passw), 1), nil
+	return uname, passw, nil
 }
 
 func identifyRegion(url string) (string, error) {
@@ -115,11 +246,11 @@ func (s *Signer) canonicalRequest(req *http.Request) []byte {
 
 // Creates a string to sign based on the following specification,
 // https://docs.aws.amazon.com/general/latest/gr/sigv4-create-string-to-sign.html
-func (s *Signer) stringToSign(cr []byte) []byte {
+func (s *Signer) stringToSign(sc scope, cr []byte) []byte {
 	sts := new(bytes.Buffer)
 	fmt.Fprint(sts, "AWS4-HMAC-SHA256\n")
-	fmt.Fprintf(sts, "%s\n", s.requestTime.Format("20060102T150405"))
-	fmt.Fprintf(sts, "%s/%s/%s/aws4_request\n", s.requestTime.Format("20060102"), s.region, s.service)
+	fmt.Fprintf(sts, "%s\n", sc.requestTime.Format("20060102T150405"))
+	fmt.Fprintf(sts, "%s/%s/%s/aws4_request\n", sc.requestTime.Format("20060102"), sc.region, s.service)
 	crHash := v4Hash(cr)
 	fmt.Fprintf(sts, "%s", fmt.Sprintf("%x", crHash))
 
@@ -128,15 +259,48 @@ func (s *Signer) stringToSign(cr []byte) []byte {
 
 // Creates the V4 signature based on the following specification,
 // https://docs.aws.amazon.com/general/latest/gr/sigv4-calculate-signature.html
-func (s *Signer) signature(sts []byte) []byte {
-	dsk := v4HMAC([]byte("AWS4"+s.credentials.SecretAccessKey), []byte(s.requestTime.Format("20060102")))
-	dsk = v4HMAC(dsk, []byte(s.region))
-	dsk = v4HMAC(dsk, []byte(s.service))
-	dsk = v4HMAC(dsk, []byte("aws4_request"))
-
+func (s *Signer) signature(sc scope, sts []byte) []byte {
+	dsk := s.keys.get(sc.credentials.SecretAccessKey, sc.requestTime.Format("20060102"), sc.region, s.service)
 	return v4HMAC(dsk, sts)
 }
 
+// Retrieves a cached signing key for the given scope, deriving and caching a new one if
+// needed. Rotating the secret or moving onto a new date will invalidate all cached keys
+func (c *derivedKeyCache) get(secret, date, region, service string) []byte {
+	scope := region + "/" + service
+
+	c.mu.RLock()
+	if c.secret == secret && c.date == date {
+		if dsk, ok := c.keys[scope]; ok {
+			c.mu.RUnlock()
+			return dsk
+		}
+	}
+	c.mu.RUnlock()
+
+	dsk := deriveKey(secret, date, region, service)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil || c.secret != secret || c.date != date {
+		c.secret = secret
+		c.date = date
+		c.keys = map[string][]byte{}
+	}
+	c.keys[scope] = dsk
+
+	return dsk
+}
+
+// Derives a signing key based on the following specification,
+// https://docs.aws.amazon.com/general/latest/gr/sigv4-calculate-signature.html
+func deriveKey(secret, date, region, service string) []byte {
+	dsk := v4HMAC([]byte("AWS4"+secret), []byte(date))
+	dsk = v4HMAC(dsk, []byte(region))
+	dsk = v4HMAC(dsk, []byte(service))
+	return v4HMAC(dsk, []byte("aws4_request"))
+}
+
 func v4Hash(in []byte) []byte {
 	h := sha256.New()
 	h.Write(in)
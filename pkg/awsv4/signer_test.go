@@ -24,11 +24,14 @@ package awsv4
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -67,7 +70,7 @@ func TestSign(t *testing.T) {
 
 	// Test password using a regex
 	passw, _ := u.User.Password()
-	rgx := regexp.MustCompile(fmt.Sprintf("^%sZ[a-zA-Z0-9]{64}$", s.requestTime.Format("20060102T150405")))
+	rgx := regexp.MustCompile("^[0-9]{8}T[0-9]{6}Z[a-zA-Z0-9]{64}$")
 	assert.True(t, rgx.MatchString(passw))
 
 	assert.Equal(t, "git-codecommit.eu-west-1.amazonaws.com", u.Host)
@@ -83,6 +86,184 @@ func TestSign_MalformedUrl(t *testing.T) {
 	assert.Empty(t, sig)
 }
 
+// fakeProvider issues a new set of credentials on every retrieval, each
+// expiring after a configured duration
+type fakeProvider struct {
+	mu        sync.Mutex
+	expiresIn time.Duration
+	retrieved int
+}
+
+func (p *fakeProvider) Retrieve(_ context.Context) (aws.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.retrieved++
+	return aws.Credentials{
+		AccessKeyID:     fmt.Sprintf("ACCESS_KEY_ID_%d", p.retrieved),
+		SecretAccessKey: fmt.Sprintf("SECRET_ACCESS_KEY_%d", p.retrieved),
+		SessionToken:    fmt.Sprintf("SESSION_TOKEN_%d", p.retrieved),
+		CanExpire:       true,
+		Expires:         time.Now().Add(p.expiresIn),
+	}, nil
+}
+
+func TestSign_CachesCredentials(t *testing.T) {
+	p := &fakeProvider{expiresIn: time.Hour}
+	s := NewProviderSigner(p)
+
+	for i := 0; i < 3; i++ {
+		surl, err := s.Sign(repoURL)
+		require.NoError(t, err)
+
+		u, _ := url.Parse(surl)
+		assert.Equal(t, "ACCESS_KEY_ID_1%SESSION_TOKEN_1", u.User.Username())
+	}
+	assert.Equal(t, 1, p.retrieved)
+}
+
+func TestSign_RefreshesCredentialsNearExpiry(t *testing.T) {
+	p := &fakeProvider{expiresIn: credentialsExpiryWindow - time.Minute}
+	s := NewProviderSigner(p)
+
+	_, err := s.Sign(repoURL)
+	require.NoError(t, err)
+
+	surl, err := s.Sign(repoURL)
+	require.NoError(t, err)
+
+	u, _ := url.Parse(surl)
+	assert.Equal(t, "ACCESS_KEY_ID_2%SESSION_TOKEN_2", u.User.Username())
+	assert.Equal(t, 2, p.retrieved)
+}
+
+func TestSign_CredentialsError(t *testing.T) {
+	s := NewProviderSigner(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.New("no credentials")
+	}))
+
+	sig, err := s.Sign(repoURL)
+
+	require.Error(t, err)
+	assert.Empty(t, sig)
+
+	var credsErr *CredentialsError
+	require.ErrorAs(t, err, &credsErr)
+	assert.ErrorContains(t, credsErr, "no credentials")
+}
+
+func TestSignContext_Cancelled(t *testing.T) {
+	s := NewSigner(aws.Credentials{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sig, err := s.SignContext(ctx, repoURL)
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, sig)
+}
+
+// blockingProvider never returns credentials, simulating a hung IMDS or SSO call.
+// It optionally ignores cancellation of the context it is given
+type blockingProvider struct {
+	ignoreContext bool
+	release       chan struct{}
+}
+
+func (p blockingProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	if p.ignoreContext {
+		<-p.release
+		return aws.Credentials{}, errors.New("released")
+	}
+
+	select {
+	case <-ctx.Done():
+		return aws.Credentials{}, ctx.Err()
+	case <-p.release:
+		return aws.Credentials{}, errors.New("released")
+	}
+}
+
+func TestSignContext_BlockingProviderTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		ignoreContext bool
+	}{
+		{name: "HonoursContext"},
+		{name: "IgnoresContext", ignoreContext: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			t.Cleanup(func() { close(release) })
+
+			s := NewProviderSigner(blockingProvider{ignoreContext: tt.ignoreContext, release: release})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			sig, err := s.SignContext(ctx, repoURL)
+
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Empty(t, sig)
+			assert.Less(t, time.Since(start), 5*time.Second)
+		})
+	}
+}
+
+func TestSignContext_ConcurrentRegions(t *testing.T) {
+	s := NewSigner(aws.Credentials{
+		AccessKeyID:     "ACCESS_KEY_ID",
+		SecretAccessKey: "SECRET_ACCESS_KEY",
+		SessionToken:    "SESSION_TOKEN",
+	})
+
+	regions := []string{
+		"us-east-1", "us-east-2", "us-west-1", "us-west-2", "eu-west-1", "eu-west-2",
+		"eu-west-3", "eu-central-1", "eu-north-1", "ap-south-1", "ap-southeast-1",
+		"ap-southeast-2", "ap-northeast-1", "ap-northeast-2", "ca-central-1", "sa-east-1",
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, region := range regions {
+			wg.Add(1)
+			go func(region string) {
+				defer wg.Done()
+
+				cloneURL := fmt.Sprintf("https://git-codecommit.%s.amazonaws.com/v1/repos/dummy-repo", region)
+				surl, err := s.SignContext(context.Background(), cloneURL)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				u, err := url.Parse(surl)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				// Recalculate the signature from the scope embedded within the signed URL
+				passw, _ := u.User.Password()
+				reqTime, err := time.Parse("20060102T150405", passw[:15])
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				sc := scope{region: region, requestTime: reqTime}
+				req, _ := http.NewRequest("GIT", cloneURL, http.NoBody)
+
+				expected := deriveKey("SECRET_ACCESS_KEY", reqTime.Format("20060102"), region, "codecommit")
+				sig := v4HMAC(expected, s.stringToSign(sc, s.canonicalRequest(req)))
+				assert.Equal(t, fmt.Sprintf("%x", sig), passw[16:])
+			}(region)
+		}
+	}
+	wg.Wait()
+}
+
 func TestIdentifyRegion(t *testing.T) {
 	rgn, err := identifyRegion(repoURL)
 
@@ -90,6 +271,13 @@ func TestIdentifyRegion(t *testing.T) {
 	assert.Equal(t, "eu-west-1", rgn)
 }
 
+func TestIdentifyRegion_FIPS(t *testing.T) {
+	rgn, err := identifyRegion("https://git-codecommit-fips.us-east-1.amazonaws.com/v1/repos/repository")
+
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", rgn)
+}
+
 func TestIdentifyRegion_MalformedUrl(t *testing.T) {
 	rgn, err := identifyRegion("https://codecommit.amazonaws.com")
 
@@ -100,10 +288,7 @@ func TestIdentifyRegion_MalformedUrl(t *testing.T) {
 
 func TestCanonicalRequest(t *testing.T) {
 	v4 := Signer{
-		service:     "codecommit",
-		region:      "eu-west-1",
-		credentials: aws.Credentials{},
-		requestTime: requestTime,
+		service: "codecommit",
 	}
 
 	// Construct a GIT request
@@ -116,10 +301,7 @@ func TestCanonicalRequest(t *testing.T) {
 
 func TestCanonicalRequest_IgnoresPayload(t *testing.T) {
 	v4 := Signer{
-		service:     "codecommit",
-		region:      "eu-west-1",
-		credentials: aws.Credentials{},
-		requestTime: requestTime,
+		service: "codecommit",
 	}
 
 	payload := []byte("payload")
@@ -136,10 +318,7 @@ func TestCanonicalRequest_IgnoresPayload(t *testing.T) {
 
 func TestCanonicalRequest_IgnoresQueryParameters(t *testing.T) {
 	v4 := Signer{
-		service:     "codecommit",
-		region:      "eu-west-1",
-		credentials: aws.Credentials{},
-		requestTime: requestTime,
+		service: "codecommit",
 	}
 
 	// Construct a GIT request
@@ -154,30 +333,125 @@ func TestCanonicalRequest_IgnoresQueryParameters(t *testing.T) {
 
 func TestStringToSign(t *testing.T) {
 	v4 := Signer{
-		service:     "codecommit",
-		region:      "eu-west-1",
-		credentials: aws.Credentials{},
-		requestTime: requestTime,
+		service: "codecommit",
 	}
 
 	canonicalReq := "GIT\n/v1/repos/dummy-repo\n\nhost:git-codecommit.eu-west-1.amazonaws.com\n\nhost\n"
 
-	sts := v4.stringToSign([]byte(canonicalReq))
+	sts := v4.stringToSign(scope{region: "eu-west-1", requestTime: requestTime}, []byte(canonicalReq))
 	assert.Equal(t, "AWS4-HMAC-SHA256\n20210901T102523\n20210901/eu-west-1/codecommit/aws4_request\nb7cad41c14b37f02e4d2deaf4f0773423b7dbe5db34af6b45362223291f968ef", string(sts))
 }
 
 func TestSignature(t *testing.T) {
 	v4 := Signer{
 		service: "codecommit",
-		region:  "eu-west-1",
-		credentials: aws.Credentials{
-			SecretAccessKey: "SECRET_ACCESS_KEY",
-		},
+	}
+	sc := scope{
+		region:      "eu-west-1",
 		requestTime: requestTime,
+		credentials: aws.Credentials{SecretAccessKey: "SECRET_ACCESS_KEY"},
 	}
 
 	stringToSign := "AWS4-HMAC-SHA256\n20210901T102523\n20210901/eu-west-1/codecommit/aws4_request\nb7cad41c14b37f02e4d2deaf4f0773423b7dbe5db34af6b45362223291f968ef"
 
-	sig := v4.signature([]byte(stringToSign))
+	sig := v4.signature(sc, []byte(stringToSign))
 	assert.Equal(t, "670ef0c32f13fc847ed0c001a2b90bc772451c1eb89eadf84a73f3e82da9f56a", fmt.Sprintf("%x", sig))
 }
+
+func TestSignature_CachesDerivedKey(t *testing.T) {
+	v4 := Signer{
+		service: "codecommit",
+	}
+	sc := scope{
+		region:      "eu-west-1",
+		requestTime: requestTime,
+		credentials: aws.Credentials{SecretAccessKey: "SECRET_ACCESS_KEY"},
+	}
+
+	v4.signature(sc, []byte("string-to-sign"))
+	sc.region = "eu-west-2"
+	v4.signature(sc, []byte("string-to-sign"))
+
+	assert.Len(t, v4.keys.keys, 2)
+	assert.Equal(t, deriveKey("SECRET_ACCESS_KEY", "20210901", "eu-west-1", "codecommit"), v4.keys.keys["eu-west-1/codecommit"])
+	assert.Equal(t, deriveKey("SECRET_ACCESS_KEY", "20210901", "eu-west-2", "codecommit"), v4.keys.keys["eu-west-2/codecommit"])
+}
+
+func TestSignature_RotatedCredentials(t *testing.T) {
+	v4 := Signer{
+		service: "codecommit",
+	}
+	sc := scope{
+		region:      "eu-west-1",
+		requestTime: requestTime,
+		credentials: aws.Credentials{SecretAccessKey: "SECRET_ACCESS_KEY"},
+	}
+
+	stringToSign := "AWS4-HMAC-SHA256\n20210901T102523\n20210901/eu-west-1/codecommit/aws4_request\nb7cad41c14b37f02e4d2deaf4f0773423b7dbe5db34af6b45362223291f968ef"
+	sig := v4.signature(sc, []byte(stringToSign))
+
+	sc.credentials.SecretAccessKey = "ROTATED_SECRET_ACCESS_KEY"
+	rotated := v4.signature(sc, []byte(stringToSign))
+
+	assert.NotEqual(t, sig, rotated)
+	assert.Len(t, v4.keys.keys, 1)
+	assert.Equal(t, v4HMAC(deriveKey("ROTATED_SECRET_ACCESS_KEY", "20210901", "eu-west-1", "codecommit"), []byte(stringToSign)), rotated)
+}
+
+func TestSignature_NewDate(t *testing.T) {
+	v4 := Signer{
+		service: "codecommit",
+	}
+	sc := scope{
+		region:      "eu-west-1",
+		requestTime: requestTime,
+		credentials: aws.Credentials{SecretAccessKey: "SECRET_ACCESS_KEY"},
+	}
+
+	v4.signature(sc, []byte("string-to-sign"))
+	sc.requestTime = requestTime.AddDate(0, 0, 1)
+	v4.signature(sc, []byte("string-to-sign"))
+
+	assert.Len(t, v4.keys.keys, 1)
+	assert.Equal(t, "20210902", v4.keys.date)
+}
+
+func BenchmarkSign(b *testing.B) {
+	s := NewSigner(aws.Credentials{
+		AccessKeyID:     "ACCESS_KEY_ID",
+		SecretAccessKey: "SECRET_ACCESS_KEY",
+		SessionToken:    "SESSION_TOKEN",
+	})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Sign(repoURL); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSign_Parallel(b *testing.B) {
+	s := NewSigner(aws.Credentials{
+		AccessKeyID:     "ACCESS_KEY_ID",
+		SecretAccessKey: "SECRET_ACCESS_KEY",
+		SessionToken:    "SESSION_TOKEN",
+	})
+
+	urls := []string{
+		"https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/dummy-repo",
+		"https://git-codecommit.us-east-1.amazonaws.com/v1/repos/dummy-repo",
+		"https://git-codecommit.ap-southeast-2.amazonaws.com/v1/repos/dummy-repo",
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := s.SignContext(context.Background(), urls[i%len(urls)]); err != nil {
+				b.Error(err)
+			}
+			i++
+		}
+	})
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package awsv4
+
+import (
+	"net/http"
+	"regexp"
+)
+
+var (
+	hostRgx = regexp.MustCompile(`^git-codecommit(-fips)?\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+	pathRgx = regexp.MustCompile(`^/v1/repos/[^/]+`)
+
+	_ http.RoundTripper = (*Transport)(nil)
+)
+
+// Transport is an http.RoundTripper that authenticates requests sent to a CodeCommit
+// repository, setting freshly signed basic authentication credentials on each one.
+// Requests to any other host, or to a CodeCommit host outside of a repository, are
+// sent untouched. Any credentials already set on a request take precedence.
+//
+// As credentials are signed for every request, a Transport can be shared by long
+// running clients, with credentials rotated by the Signer as they near expiry
+type Transport struct {
+	// Signer signs the repository targeted by each request
+	Signer *Signer
+
+	// Base sends each request, defaulting to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// RoundTrip signs a request if it targets a CodeCommit repository, before sending it
+// using the base RoundTripper. The original request is never modified
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	repoURL, ok := repository(r)
+	if !ok || r.Header.Get("Authorization") != "" {
+		return base.RoundTrip(r)
+	}
+
+	uname, passw, err := t.Signer.credentialsFor(r.Context(), repoURL)
+	if err != nil {
+		// A RoundTripper must always close the body, even when returning an error
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+
+	signed := r.Clone(r.Context())
+	signed.SetBasicAuth(uname, passw)
+	return base.RoundTrip(signed)
+}
+
+// Identifies the URL of the CodeCommit repository targeted by a request. The hostname
+// must exactly match a CodeCommit endpoint, and any port is dropped from the URL, as
+// the signature is always generated for the bare hostname
+func repository(r *http.Request) (string, bool) {
+	if r.URL.Scheme != "https" || !hostRgx.MatchString(r.URL.Hostname()) {
+		return "", false
+	}
+
+	path := pathRgx.FindString(r.URL.Path)
+	if path == "" {
+		return "", false
+	}
+	return "https://" + r.URL.Hostname() + path, true
+}
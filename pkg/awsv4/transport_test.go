@@ -0,0 +1,338 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package awsv4
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/gembaadvantage/codecommit-sign/internal/gittest"
+	"github.com/gembaadvantage/codecommit-sign/pkg/fakecodecommit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const refsURL = "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository/info/refs?service=git-upload-pack"
+
+var (
+	firstKey = fakecodecommit.Key{
+		AccessKeyID:     "ASIAFIRSTEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY",
+		SessionToken:    "a/b+c==",
+	}
+	secondKey = fakecodecommit.Key{
+		AccessKeyID:     "ASIASECONDEXAMPLE",
+		SecretAccessKey: "je7MtGbClwBF/2Zp9Utk/h3yCo8nvbEXAMPLEKEY",
+		SessionToken:    "d+e/f==",
+	}
+)
+
+// recorder captures the basic authentication username of every request
+type recorder struct {
+	mu    sync.Mutex
+	users []string
+}
+
+func (r *recorder) record(req *http.Request) {
+	user, _, _ := req.BasicAuth()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users = append(r.users, user)
+}
+
+func (r *recorder) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.users[len(r.users)-1]
+}
+
+// Serves a fake CodeCommit repository over TLS, returning a RoundTripper that sends
+// any request to the server, as if it were hosted by CodeCommit
+func serveTLS(t *testing.T, rec *recorder) http.RoundTripper {
+	t.Helper()
+
+	s := &fakecodecommit.Server{
+		Root:   t.TempDir(),
+		Region: "eu-west-1",
+		Keys:   []fakecodecommit.Key{firstKey, secondKey},
+	}
+	gittest.BareRepo(t, filepath.Join(s.Root, "repository.git"))
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.record(r)
+		s.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	base := srv.Client().Transport.(*http.Transport).Clone()
+	base.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	// The certificate of the test server is only valid for example.com
+	base.TLSClientConfig.ServerName = "example.com"
+	return base
+}
+
+// rotatingProvider returns credentials that always need refreshing, until rotated
+type rotatingProvider struct {
+	mu  sync.Mutex
+	key fakecodecommit.Key
+}
+
+func (p *rotatingProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return aws.Credentials{
+		AccessKeyID:     p.key.AccessKeyID,
+		SecretAccessKey: p.key.SecretAccessKey,
+		SessionToken:    p.key.SessionToken,
+		CanExpire:       true,
+		Expires:         time.Now().Add(time.Minute),
+	}, nil
+}
+
+func (p *rotatingProvider) rotate(k fakecodecommit.Key) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.key = k
+}
+
+func get(t *testing.T, c *http.Client, url string) int {
+	t.Helper()
+
+	resp, err := c.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+func TestTransport(t *testing.T) {
+	rec := &recorder{}
+	c := &http.Client{Transport: &Transport{
+		Signer: NewSigner(aws.Credentials{
+			AccessKeyID:     firstKey.AccessKeyID,
+			SecretAccessKey: firstKey.SecretAccessKey,
+			SessionToken:    firstKey.SessionToken,
+		}),
+		Base: serveTLS(t, rec),
+	}}
+
+	assert.Equal(t, http.StatusOK, get(t, c, refsURL))
+	assert.Equal(t, "ASIAFIRSTEXAMPLE%a/b+c==", rec.last())
+}
+
+func TestTransport_Unsigned(t *testing.T) {
+	rec := &recorder{}
+	c := &http.Client{Transport: serveTLS(t, rec)}
+
+	assert.Equal(t, http.StatusUnauthorized, get(t, c, refsURL))
+}
+
+func TestTransport_CredentialRotation(t *testing.T) {
+	rec := &recorder{}
+	provider := &rotatingProvider{key: firstKey}
+	c := &http.Client{Transport: &Transport{Signer: NewProviderSigner(provider), Base: serveTLS(t, rec)}}
+
+	assert.Equal(t, http.StatusOK, get(t, c, refsURL))
+	assert.True(t, strings.HasPrefix(rec.last(), firstKey.AccessKeyID+"%"))
+
+	provider.rotate(secondKey)
+
+	assert.Equal(t, http.StatusOK, get(t, c, refsURL))
+	assert.True(t, strings.HasPrefix(rec.last(), secondKey.AccessKeyID+"%"))
+}
+
+func TestTransport_ConcurrentRotation(t *testing.T) {
+	rec := &recorder{}
+	provider := &rotatingProvider{key: firstKey}
+	c := &http.Client{Transport: &Transport{Signer: NewProviderSigner(provider), Base: serveTLS(t, rec)}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				provider.rotate(secondKey)
+			} else {
+				provider.rotate(firstKey)
+			}
+
+			resp, err := c.Get(refsURL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// echo records the Authorization header of the last request it received
+type echo struct {
+	header string
+}
+
+func (e *echo) RoundTrip(r *http.Request) (*http.Response, error) {
+	e.header = r.Header.Get("Authorization")
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+}
+
+func TestTransport_Matching(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		signed bool
+	}{
+		{
+			name:   "Repository",
+			url:    refsURL,
+			signed: true,
+		},
+		{
+			name:   "RepositoryChina",
+			url:    "https://git-codecommit.cn-north-1.amazonaws.com.cn/v1/repos/repository/git-upload-pack",
+			signed: true,
+		},
+		{
+			name:   "RepositoryFIPS",
+			url:    "https://git-codecommit-fips.us-east-1.amazonaws.com/v1/repos/repository/info/refs",
+			signed: true,
+		},
+		{
+			name:   "RepositoryWithPort",
+			url:    "https://git-codecommit.eu-west-1.amazonaws.com:443/v1/repos/repository/info/refs",
+			signed: true,
+		},
+		{
+			name: "OtherHost",
+			url:  "https://github.com/org/repository/info/refs",
+		},
+		{
+			name: "S3Lookalike",
+			url:  "https://git-codecommit.bucket.s3.amazonaws.com/v1/repos/repository/info/refs",
+		},
+		{
+			name: "LookalikeSuffix",
+			url:  "https://git-codecommit.eu-west-1.amazonaws.com.example.com/v1/repos/repository/info/refs",
+		},
+		{
+			name: "OtherHostWithRepositoryPath",
+			url:  "https://example.com/v1/repos/repository/info/refs",
+		},
+		{
+			name: "NotARepository",
+			url:  "https://git-codecommit.eu-west-1.amazonaws.com/health",
+		},
+		{
+			name: "Insecure",
+			url:  "http://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository/info/refs",
+		},
+		{
+			name:   "ExistingCredentials",
+			url:    refsURL,
+			header: "Basic dXNlcjpwYXNz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := &echo{}
+			tr := &Transport{Signer: NewSigner(aws.Credentials{AccessKeyID: "ACCESS_KEY_ID"}), Base: base}
+
+			req, err := http.NewRequest(http.MethodGet, tt.url, http.NoBody)
+			require.NoError(t, err)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			_, err = tr.RoundTrip(req)
+			require.NoError(t, err)
+
+			if tt.signed {
+				assert.True(t, strings.HasPrefix(base.header, "Basic "))
+				assert.Empty(t, req.Header.Get("Authorization"), "original request must not be modified")
+			} else {
+				assert.Equal(t, tt.header, base.header)
+			}
+		})
+	}
+}
+
+func TestTransport_SignsRepositoryWithoutPort(t *testing.T) {
+	var signed string
+	signer := NewSigner(aws.Credentials{AccessKeyID: "ACCESS_KEY_ID"}, WithDebug(func(d Debug) {
+		signed = d.URL
+	}))
+	tr := &Transport{Signer: signer, Base: &echo{}}
+
+	req, err := http.NewRequest(http.MethodGet, "https://git-codecommit.eu-west-1.amazonaws.com:443/v1/repos/repository/info/refs", http.NoBody)
+	require.NoError(t, err)
+
+	_, err = tr.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository", signed)
+}
+
+// closeRecorder records if a request body was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestTransport_SignError(t *testing.T) {
+	tr := &Transport{
+		Signer: NewProviderSigner(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, errors.New("no credentials")
+		})),
+		Base: &echo{},
+	}
+
+	body := &closeRecorder{Reader: strings.NewReader("0000")}
+	req, err := http.NewRequest(http.MethodPost, "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository/git-upload-pack", body)
+	require.NoError(t, err)
+
+	_, err = tr.RoundTrip(req)
+	require.ErrorContains(t, err, "no credentials")
+	assert.True(t, body.closed)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package credproc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Version of the credential_process output format
+const Version = 1
+
+// Output contains credentials in the format expected from a credential_process
+// by the AWS CLI and SDKs, see:
+// https://docs.aws.amazon.com/sdkref/latest/guide/feature-process-credentials.html
+type Output struct {
+	Version         int    `json:"Version"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken,omitempty"`
+	Expiration      string `json:"Expiration,omitempty"`
+}
+
+// New converts a set of AWS credentials into the credential_process output format.
+// An expiration is only included if the credentials can expire
+func New(creds aws.Credentials) (Output, error) {
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Output{}, errors.New("credentials must contain both an access key id and secret access key")
+	}
+
+	out := Output{
+		Version:         Version,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}
+
+	if creds.CanExpire {
+		out.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+	}
+	return out, nil
+}
+
+// Write writes a set of AWS credentials in the credential_process output format
+func Write(w io.Writer, creds aws.Credentials) error {
+	out, err := New(creds)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// Read parses a set of AWS credentials from the credential_process output format
+func Read(r io.Reader) (aws.Credentials, error) {
+	var in Output
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return aws.Credentials{}, fmt.Errorf("malformed credentials: %w", err)
+	}
+
+	if in.Version != Version {
+		return aws.Credentials{}, fmt.Errorf("unsupported credentials version %d, must be %d", in.Version, Version)
+	}
+
+	if in.AccessKeyID == "" || in.SecretAccessKey == "" {
+		return aws.Credentials{}, errors.New("credentials must contain both an access key id and secret access key")
+	}
+
+	creds := aws.Credentials{
+		AccessKeyID:     in.AccessKeyID,
+		SecretAccessKey: in.SecretAccessKey,
+		SessionToken:    in.SessionToken,
+	}
+
+	if in.Expiration != "" {
+		expires, err := time.Parse(time.RFC3339, in.Expiration)
+		if err != nil {
+			return aws.Credentials{}, fmt.Errorf("malformed credentials expiration: %w", err)
+		}
+
+		creds.CanExpire = true
+		creds.Expires = expires
+	}
+	return creds, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package fakecodecommit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Key is a set of AWS credentials accepted by the server
+type Key struct {
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken must accompany the access key, if set
+	SessionToken string
+}
+
+var (
+	errMissingCredentials = errors.New("missing credentials")
+	errMalformedPassword  = errors.New("malformed signature")
+	errUnknownAccessKey   = errors.New("the security token included in the request is invalid")
+	errSessionToken       = errors.New("the session token included in the request is invalid")
+	errSignatureExpired   = errors.New("signature expired")
+	errSignatureFuture    = errors.New("signature not yet current")
+	errSignatureMismatch  = errors.New("the request signature we calculated does not match the signature you provided")
+)
+
+// Verifies the basic auth credentials of a request were signed for the given
+// repository path, using the AWS authenticated V4 signature specification. This is
+// deliberately independent of the awsv4 package, ensuring it can verify it
+func (s *Server) verify(r *http.Request, repoPath string) (Key, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return Key{}, errMissingCredentials
+	}
+
+	// The username contains the access key id, followed by an optional session token
+	akid, token, _ := strings.Cut(user, "%")
+
+	key, ok := s.key(akid)
+	if !ok {
+		return Key{}, errUnknownAccessKey
+	}
+
+	if token != key.SessionToken {
+		return Key{}, errSessionToken
+	}
+
+	// The password contains the request time, followed by the hex encoded signature
+	ts, sig, ok := strings.Cut(pass, "Z")
+	if !ok {
+		return Key{}, errMalformedPassword
+	}
+
+	reqTime, err := time.Parse("20060102T150405", ts)
+	if err != nil {
+		return Key{}, errMalformedPassword
+	}
+
+	now := s.now()
+	if now.Sub(reqTime) > s.maxAge() {
+		return Key{}, errSignatureExpired
+	}
+
+	if reqTime.Sub(now) > s.maxAge() {
+		return Key{}, errSignatureFuture
+	}
+
+	want := signature(key.SecretAccessKey, s.Region, s.host(), repoPath, reqTime)
+	if !hmac.Equal([]byte(want), []byte(sig)) {
+		return Key{}, errSignatureMismatch
+	}
+
+	return key, nil
+}
+
+// Calculates the signature CodeCommit expects for a repository. Only the path of
+// the repository and the host are signed, using the GIT method
+func signature(secret, region, host, repoPath string, t time.Time) string {
+	date := t.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/codecommit/aws4_request", date, region)
+
+	canonical := strings.Join([]string{
+		"GIT",
+		repoPath,
+		"",
+		"host:" + host,
+		"",
+		"host",
+		"",
+	}, "\n")
+
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format("20060102T150405"),
+		scope,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "codecommit")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
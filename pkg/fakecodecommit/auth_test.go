@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package fakecodecommit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var requestTime = time.Date(2021, 9, 1, 10, 25, 23, 0, time.UTC)
+
+func TestSignature(t *testing.T) {
+	sig := signature("SECRET_ACCESS_KEY", "eu-west-1", "git-codecommit.eu-west-1.amazonaws.com", "/v1/repos/dummy-repo", requestTime)
+
+	assert.Equal(t, "670ef0c32f13fc847ed0c001a2b90bc772451c1eb89eadf84a73f3e82da9f56a", sig)
+}
+
+func TestVerify(t *testing.T) {
+	s := &Server{
+		Region: "eu-west-1",
+		Keys: []Key{
+			{AccessKeyID: "ACCESS_KEY_ID", SecretAccessKey: "SECRET_ACCESS_KEY"},
+			{AccessKeyID: "SESSION_KEY_ID", SecretAccessKey: "SECRET_ACCESS_KEY", SessionToken: "SESSION_TOKEN"},
+		},
+		Now: func() time.Time { return requestTime.Add(time.Minute) },
+	}
+
+	validPass := "20210901T102523Z670ef0c32f13fc847ed0c001a2b90bc772451c1eb89eadf84a73f3e82da9f56a"
+
+	tests := []struct {
+		name string
+		user string
+		pass string
+		err  error
+	}{
+		{name: "Valid", user: "ACCESS_KEY_ID", pass: validPass},
+		{name: "ValidWithSessionToken", user: "SESSION_KEY_ID%SESSION_TOKEN", pass: validPass},
+		{name: "UnknownAccessKey", user: "UNKNOWN", pass: validPass, err: errUnknownAccessKey},
+		{name: "MissingSessionToken", user: "SESSION_KEY_ID", pass: validPass, err: errSessionToken},
+		{name: "UnexpectedSessionToken", user: "ACCESS_KEY_ID%SESSION_TOKEN", pass: validPass, err: errSessionToken},
+		{name: "MalformedPassword", user: "ACCESS_KEY_ID", pass: "password", err: errMalformedPassword},
+		{name: "MalformedTime", user: "ACCESS_KEY_ID", pass: "2021-09-01Z670ef0c3", err: errMalformedPassword},
+		{name: "Mismatch", user: "ACCESS_KEY_ID", pass: "20210901T102523Z0000", err: errSignatureMismatch},
+		{name: "Expired", user: "ACCESS_KEY_ID", pass: "20210901T100000Z670ef0c32f13fc847ed0c001a2b90bc772451c1eb89eadf84a73f3e82da9f56a", err: errSignatureExpired},
+		{name: "Future", user: "ACCESS_KEY_ID", pass: "20210901T110000Z670ef0c32f13fc847ed0c001a2b90bc772451c1eb89eadf84a73f3e82da9f56a", err: errSignatureFuture},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/v1/repos/dummy-repo/info/refs", http.NoBody)
+			r.SetBasicAuth(tt.user, tt.pass)
+
+			_, err := s.verify(r, "/v1/repos/dummy-repo")
+			if tt.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerify_WrongRegion(t *testing.T) {
+	s := &Server{
+		Region: "us-east-1",
+		Keys:   []Key{{AccessKeyID: "ACCESS_KEY_ID", SecretAccessKey: "SECRET_ACCESS_KEY"}},
+		Now:    func() time.Time { return requestTime },
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/v1/repos/dummy-repo/info/refs", http.NoBody)
+	r.SetBasicAuth("ACCESS_KEY_ID", "20210901T102523Z670ef0c32f13fc847ed0c001a2b90bc772451c1eb89eadf84a73f3e82da9f56a")
+
+	_, err := s.verify(r, "/v1/repos/dummy-repo")
+	require.ErrorIs(t, err, errSignatureMismatch)
+}
+
+func TestVerify_MissingCredentials(t *testing.T) {
+	s := &Server{Region: "eu-west-1"}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/v1/repos/dummy-repo/info/refs", http.NoBody)
+
+	_, err := s.verify(r, "/v1/repos/dummy-repo")
+	require.ErrorIs(t, err, errMissingCredentials)
+}
+
+func TestHost(t *testing.T) {
+	tests := []struct {
+		name   string
+		server Server
+		host   string
+	}{
+		{name: "Region", server: Server{Region: "eu-west-1"}, host: "git-codecommit.eu-west-1.amazonaws.com"},
+		{name: "ChinaRegion", server: Server{Region: "cn-north-1"}, host: "git-codecommit.cn-north-1.amazonaws.com.cn"},
+		{name: "Override", server: Server{Region: "eu-west-1", Host: "localhost"}, host: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.host, tt.server.host())
+		})
+	}
+}
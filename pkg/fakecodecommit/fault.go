@@ -0,0 +1,76 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package fakecodecommit
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Fault is a failure that can be simulated by the server
+type Fault string
+
+const (
+	// ExpiredSignature rejects a request as if its signature had expired
+	ExpiredSignature Fault = "expired-signature"
+
+	// WrongRegion rejects a request as if it had been signed for a different region
+	WrongRegion Fault = "wrong-region"
+
+	// Throttle rejects a request as if the rate of requests had been exceeded
+	Throttle Fault = "throttle"
+)
+
+// Faults lists every fault that can be simulated
+var Faults = []Fault{ExpiredSignature, WrongRegion, Throttle}
+
+// Always simulates a fault on every request
+func Always(f Fault) func(*http.Request) Fault {
+	return func(*http.Request) Fault {
+		return f
+	}
+}
+
+// FirstN simulates a fault on the first n requests only. Every request made by git,
+// including the initial discovery of refs, counts as a separate request
+func FirstN(n int, f Fault) func(*http.Request) Fault {
+	var count atomic.Int64
+	return func(*http.Request) Fault {
+		if count.Add(1) <= int64(n) {
+			return f
+		}
+		return ""
+	}
+}
+
+// Writes the response CodeCommit returns for a fault
+func writeFault(w http.ResponseWriter, f Fault) {
+	switch f {
+	case ExpiredSignature:
+		http.Error(w, errSignatureExpired.Error(), http.StatusForbidden)
+	case WrongRegion:
+		http.Error(w, "credential should be scoped to a valid region", http.StatusForbidden)
+	case Throttle:
+		http.Error(w, "rate exceeded", http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package fakecodecommit
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cgi"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxAge is how long a signature remains valid for, if not overridden
+	DefaultMaxAge = 15 * time.Minute
+
+	reposPrefix = "/v1/repos/"
+)
+
+// Server is a fake CodeCommit git server that serves bare repositories from disk
+// over the git smart-HTTP protocol. Every request must be authenticated using
+// credentials signed with the AWS authenticated V4 signature specification, exactly
+// as CodeCommit expects. Repositories are served under /v1/repos/<name>
+type Server struct {
+	// Root is the directory containing the bare repositories, each named either
+	// <name> or <name>.git
+	Root string
+
+	// Region the server pretends to be hosted within, which must match the region
+	// each request was signed for
+	Region string
+
+	// Keys contains the credentials accepted by the server
+	Keys []Key
+
+	// Host is the canonical host requests are signed for, defaulting to the
+	// CodeCommit host for the region. Requests can be sent to the server using any
+	// host, as it is not part of the basic auth credentials
+	Host string
+
+	// MaxAge is how long a signature remains valid for, defaulting to DefaultMaxAge
+	MaxAge time.Duration
+
+	// Fault, if set, is called for every request and can simulate a failure
+	Fault func(*http.Request) Fault
+
+	// Now returns the current time, defaulting to time.Now
+	Now func() time.Time
+
+	// Logger records every request. By default all logs are discarded
+	Logger *slog.Logger
+}
+
+// ServeHTTP authenticates a git request before handing it to git http-backend
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger().With("method", r.Method, "path", r.URL.Path)
+
+	name, rest, ok := splitRepoPath(r.URL.Path)
+	if !ok {
+		logger.Info("rejected request outside of a repository")
+		http.NotFound(w, r)
+		return
+	}
+	logger = logger.With("repository", name)
+
+	if s.Fault != nil {
+		if f := s.Fault(r); f != "" {
+			logger.Info("simulated fault", "fault", f)
+			writeFault(w, f)
+			return
+		}
+	}
+
+	key, err := s.verify(r, reposPrefix+name)
+	if err != nil {
+		logger.Info("rejected credentials", "error", err)
+		if errors.Is(err, errMissingCredentials) {
+			// Prompts git to retrieve credentials, such as from a credential helper
+			w.Header().Set("WWW-Authenticate", `Basic realm="codecommit"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	logger = logger.With("access_key_id", key.AccessKeyID)
+
+	dir, ok := s.repoDir(name)
+	if !ok {
+		logger.Info("repository does not exist")
+		http.Error(w, fmt.Sprintf("repository %s does not exist", name), http.StatusNotFound)
+		return
+	}
+
+	git, err := exec.LookPath("git")
+	if err != nil {
+		logger.Error("git is not installed", "error", err)
+		http.Error(w, "git is not installed", http.StatusInternalServerError)
+		return
+	}
+
+	// Map the requested repository onto its directory on disk
+	req := r.Clone(r.Context())
+	req.URL.Path = reposPrefix + dir + rest
+
+	h := &cgi.Handler{
+		Path: git,
+		Args: []string{"http-backend"},
+		Root: strings.TrimSuffix(reposPrefix, "/"),
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + s.Root,
+			"GIT_HTTP_EXPORT_ALL=1",
+			// Setting a remote user enables pushing over smart-HTTP
+			"REMOTE_USER=" + key.AccessKeyID,
+		},
+		InheritEnv: []string{"PATH", "HOME"},
+	}
+
+	logger.Info("serving request")
+	h.ServeHTTP(w, req)
+}
+
+// Splits a request path into the name of the repository and the remaining path
+// used by the git smart-HTTP protocol
+func splitRepoPath(path string) (name, rest string, ok bool) {
+	trimmed, ok := strings.CutPrefix(path, reposPrefix)
+	if !ok {
+		return "", "", false
+	}
+
+	name, rest, _ = strings.Cut(trimmed, "/")
+	if name == "" || name == "." || name == ".." {
+		return "", "", false
+	}
+
+	if rest != "" {
+		rest = "/" + rest
+	}
+	return name, rest, true
+}
+
+// Identifies the directory of a bare repository beneath the root
+func (s *Server) repoDir(name string) (string, bool) {
+	for _, dir := range []string{name, name + ".git"} {
+		if fi, err := os.Stat(filepath.Join(s.Root, dir, "HEAD")); err == nil && !fi.IsDir() {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+func (s *Server) key(akid string) (Key, bool) {
+	for _, k := range s.Keys {
+		if k.AccessKeyID == akid {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+func (s *Server) host() string {
+	if s.Host != "" {
+		return s.Host
+	}
+
+	host := fmt.Sprintf("git-codecommit.%s.amazonaws.com", s.Region)
+	if strings.HasPrefix(s.Region, "cn-") {
+		host += ".cn"
+	}
+	return host
+}
+
+func (s *Server) maxAge() time.Duration {
+	if s.MaxAge > 0 {
+		return s.MaxAge
+	}
+	return DefaultMaxAge
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
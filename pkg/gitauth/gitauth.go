@@ -0,0 +1,187 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package gitauth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+)
+
+// SignFunc generates a signed CodeCommit URL from an unsigned one, using credentials
+// from the named profile. An empty profile identifies the default credentials
+type SignFunc func(ctx context.Context, url, profile string) (string, error)
+
+// RegionFunc resolves a region for a CodeCommit URL that does not contain one, such
+// as a GRC URL. Any named profile embedded within the URL is provided
+type RegionFunc func(ctx context.Context, profile string) (string, error)
+
+// ConfigEntry is a single git config key and value
+type ConfigEntry struct {
+	Key   string
+	Value string
+}
+
+// Invocation is a git command that has been prepared for authenticating against
+// CodeCommit without persisting any credentials
+type Invocation struct {
+	// Args contains the rewritten git arguments. Any GRC URL will have been
+	// translated into its HTTPS equivalent
+	Args []string
+
+	// Config contains transient git config that should be applied to the command
+	Config []ConfigEntry
+}
+
+// Rewrite scans a set of git arguments for CodeCommit HTTPS and GRC URLs. Each GRC
+// URL is translated into its HTTPS equivalent, and an http.<url>.extraHeader config
+// entry is generated per URL, containing signed basic authentication credentials.
+// Any named profile within a GRC URL is passed to the sign function. A GRC URL
+// without a region is resolved through FromGRC. As the URL itself is never signed,
+// git will never persist the credentials
+func Rewrite(ctx context.Context, args []string, sign SignFunc, region RegionFunc) (Invocation, error) {
+	inv := Invocation{
+		Args:   make([]string, 0, len(args)),
+		Config: []ConfigEntry{},
+	}
+
+	for _, arg := range args {
+		var profile string
+		if strings.HasPrefix(arg, "codecommit:") {
+			if _, err := translate.RemoteGRC(arg); err == nil {
+				if arg, profile, err = FromGRC(ctx, arg, region); err != nil {
+					return Invocation{}, err
+				}
+			}
+		}
+		inv.Args = append(inv.Args, arg)
+
+		rem, err := translate.RemoteHTTPS(arg)
+		if err != nil {
+			continue
+		}
+
+		// Strip any existing credentials from the URL
+		uurl, err := translate.ToHTTPS(rem)
+		if err != nil {
+			return Invocation{}, err
+		}
+
+		header, err := ExtraHeader(ctx, uurl, profile, sign)
+		if err != nil {
+			return Invocation{}, err
+		}
+		inv.Config = append(inv.Config, header)
+	}
+
+	return inv, nil
+}
+
+// FromGRC translates a CodeCommit GRC URL into its unsigned HTTPS equivalent,
+// returning any named profile embedded within it. If the URL contains no region,
+// it is resolved through the region function. Without one, the region is taken
+// from the AWS_REGION or AWS_DEFAULT_REGION environment variables
+func FromGRC(ctx context.Context, grcURL string, region RegionFunc) (string, string, error) {
+	rem, err := translate.RemoteGRC(grcURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if region == nil {
+		httpsURL, err := translate.FromGRC(grcURL)
+		return httpsURL, rem.Profile, err
+	}
+
+	if rem.Region == "" {
+		if rem.Region, err = region(ctx, rem.Profile); err != nil {
+			return "", "", err
+		}
+	}
+
+	httpsURL, err := translate.ToHTTPS(rem)
+	return httpsURL, rem.Profile, err
+}
+
+// ExtraHeader generates an http.<url>.extraHeader config entry for an unsigned
+// CodeCommit HTTPS URL, containing a basic authorization header populated from
+// the credentials of its signed equivalent. The URL is signed using credentials from
+// the named profile
+func ExtraHeader(ctx context.Context, cloneURL, profile string, sign SignFunc) (ConfigEntry, error) {
+	surl, err := sign(ctx, cloneURL, profile)
+	if err != nil {
+		return ConfigEntry{}, err
+	}
+
+	u, err := url.Parse(surl)
+	if err != nil {
+		return ConfigEntry{}, err
+	}
+
+	passw, _ := u.User.Password()
+	creds := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + passw))
+
+	return ConfigEntry{
+		Key:   fmt.Sprintf("http.%s.extraHeader", cloneURL),
+		Value: "Authorization: Basic " + creds,
+	}, nil
+}
+
+// Env appends the transient git config of the invocation to an existing environment,
+// using the GIT_CONFIG_COUNT, GIT_CONFIG_KEY_<n> and GIT_CONFIG_VALUE_<n> variables.
+// These behave identically to passing config through git -c, but ensure credentials
+// are never visible within the arguments of a running process
+func (i Invocation) Env(environ []string) []string {
+	env := make([]string, 0, len(environ)+len(i.Config)*2+1)
+
+	// Respect any transient config that has already been provided
+	count := 0
+	for _, e := range environ {
+		if v, ok := strings.CutPrefix(e, "GIT_CONFIG_COUNT="); ok {
+			count, _ = strconv.Atoi(v)
+			continue
+		}
+		env = append(env, e)
+	}
+
+	for _, c := range i.Config {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count, c.Key),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count, c.Value))
+		count++
+	}
+
+	return append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", count))
+}
+
+// Command prepares the git command of the invocation for execution
+func (i Invocation) Command(ctx context.Context, environ []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", i.Args...)
+	cmd.Env = i.Env(environ)
+	return cmd
+}
@@ -0,0 +1,161 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package gogit authenticates go-git against CodeCommit, using credentials signed
+// with the AWS authenticated V4 signature specification. Credentials are signed
+// on demand, each time go-git makes a request, so never go stale within a long
+// running process
+package gogit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gembaadvantage/codecommit-sign/pkg/awsv4"
+	"github.com/gembaadvantage/codecommit-sign/pkg/gitauth"
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+var _ githttp.AuthMethod = (*Auth)(nil)
+
+// Endpoint translates a CodeCommit HTTPS, GRC or SSH URL into the HTTPS URL
+// understood by go-git. If a GRC URL contains no region, it is taken from the
+// AWS_REGION or AWS_DEFAULT_REGION environment variables
+func Endpoint(cloneURL string) (string, error) {
+	return ResolveEndpoint(context.Background(), cloneURL, nil)
+}
+
+// ResolveEndpoint behaves like Endpoint, but resolves the region of a GRC URL that
+// does not contain one through the region function, such as codecommitsign.Client.Region.
+// The result can be passed to NewAuth
+func ResolveEndpoint(ctx context.Context, cloneURL string, region gitauth.RegionFunc) (string, error) {
+	if strings.HasPrefix(cloneURL, "codecommit:") {
+		httpsURL, _, err := gitauth.FromGRC(ctx, cloneURL, region)
+		return httpsURL, err
+	}
+
+	rem, err := translate.Normalize(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	return translate.ToHTTPS(rem)
+}
+
+// Auth implements the go-git http.AuthMethod for a single CodeCommit repository,
+// setting freshly signed basic authentication credentials on every request. As a
+// signature only covers the repository and its CodeCommit host, requests can be
+// sent through any URL, such as a proxy or a local mirror
+type Auth struct {
+	signer *awsv4.Signer
+	url    string
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewAuth creates a new Auth that signs credentials for a CodeCommit repository,
+// identified by any HTTPS, GRC or SSH URL
+func NewAuth(signer *awsv4.Signer, cloneURL string) (*Auth, error) {
+	httpsURL, err := Endpoint(cloneURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auth{signer: signer, url: httpsURL}, nil
+}
+
+// URL returns the CodeCommit HTTPS URL of the repository
+func (a *Auth) URL() string {
+	return a.url
+}
+
+// Name identifies the authentication method
+func (a *Auth) Name() string {
+	return "http-codecommit-sign"
+}
+
+// String describes the authentication method, without exposing any credentials
+func (a *Auth) String() string {
+	return fmt.Sprintf("%s - %s", a.Name(), a.url)
+}
+
+// SetAuth sets signed basic authentication credentials on the request. go-git
+// offers no way of reporting a failure, so if signing fails the request is left
+// unauthenticated and the error is available through Err
+func (a *Auth) SetAuth(r *http.Request) {
+	user, passw, err := credentials(r.Context(), a.signer, a.url)
+
+	a.mu.Lock()
+	a.err = err
+	a.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+	r.SetBasicAuth(user, passw)
+}
+
+// Err returns the error from the most recent attempt at signing credentials
+func (a *Auth) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// NewClient creates a go-git transport that signs every request sent to a CodeCommit
+// host, leaving all other requests untouched. Register it with go-git to
+// authenticate any CodeCommit HTTPS URL without providing an AuthMethod:
+//
+//	client.InstallProtocol("https", gogit.NewClient(signer, nil))
+//
+// If c is nil, http.DefaultClient is wrapped
+func NewClient(signer *awsv4.Signer, c *http.Client) transport.Transport {
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	wrapped := *c
+	wrapped.Transport = &awsv4.Transport{Signer: signer, Base: c.Transport}
+	return githttp.NewClient(&wrapped)
+}
+
+// Signs a repository URL, returning the basic authentication credentials within it
+func credentials(ctx context.Context, signer *awsv4.Signer, cloneURL string) (string, string, error) {
+	surl, err := signer.SignContext(ctx, cloneURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	u, err := url.Parse(surl)
+	if err != nil {
+		return "", "", err
+	}
+
+	passw, _ := u.User.Password()
+	return u.User.Username(), passw, nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/git"
+	"github.com/gembaadvantage/codecommit-sign/pkg/gitauth"
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+)
+
+// Mirror synchronises all refs from one git repository to another, through a local
+// bare repository. Either side of the mirror can be a CodeCommit HTTPS or GRC URL,
+// which will be signed on the fly, while any other URL is passed to git untouched
+type Mirror struct {
+	// From is the URL of the repository being mirrored
+	From string
+
+	// To is the URL of the repository receiving the mirrored refs
+	To string
+
+	// Refs optionally restricts the mirror to refs matching any of the given patterns,
+	// such as refs/heads/* or refs/tags/v*. By default all refs are mirrored
+	Refs []string
+
+	// Dir is the path to the local bare repository used when mirroring. It will be
+	// created if it doesn't exist and can be safely reused between syncs
+	Dir string
+
+	// Sign is used to generate a signed URL for any CodeCommit repository. Any named
+	// profile within a GRC URL is passed through to it
+	Sign gitauth.SignFunc
+
+	// Region resolves a region for any GRC URL that does not contain one. If nil, it
+	// is taken from the AWS_REGION or AWS_DEFAULT_REGION environment variables
+	Region gitauth.RegionFunc
+}
+
+// Sync fetches all matching refs from the source repository and pushes them to the
+// target repository. Refs that no longer exist in the source will be deleted
+func (m Mirror) Sync(ctx context.Context) error {
+	if err := m.init(ctx); err != nil {
+		return err
+	}
+
+	from, err := m.resolve(ctx, m.From)
+	if err != nil {
+		return err
+	}
+
+	to, err := m.resolve(ctx, m.To)
+	if err != nil {
+		return err
+	}
+
+	refspecs := m.refspecs()
+
+	args := append([]string{"fetch", "--prune", "--quiet", from}, refspecs...)
+	if _, err := git.Run(ctx, m.Dir, args...); err != nil {
+		return err
+	}
+
+	if len(m.Refs) == 0 {
+		_, err = git.Run(ctx, m.Dir, "push", "--mirror", "--quiet", to)
+		return err
+	}
+
+	args = append([]string{"push", "--prune", "--quiet", to}, refspecs...)
+	_, err = git.Run(ctx, m.Dir, args...)
+	return err
+}
+
+func (m Mirror) init(ctx context.Context) error {
+	if m.Dir == "" {
+		return errors.New("no mirror directory provided")
+	}
+
+	if _, err := os.Stat(filepath.Join(m.Dir, "HEAD")); err == nil {
+		return nil
+	}
+
+	_, err := git.Run(ctx, "", "init", "--bare", "--quiet", m.Dir)
+	return err
+}
+
+func (m Mirror) refspecs() []string {
+	if len(m.Refs) == 0 {
+		return []string{"+refs/*:refs/*"}
+	}
+
+	specs := make([]string, 0, len(m.Refs))
+	for _, ref := range m.Refs {
+		specs = append(specs, fmt.Sprintf("+%s:%s", ref, ref))
+	}
+	return specs
+}
+
+// Signs a URL if it identifies a CodeCommit repository, translating any GRC URL
+// into its HTTPS equivalent beforehand. Any named profile within a GRC URL is kept
+// for signing
+func (m Mirror) resolve(ctx context.Context, url string) (string, error) {
+	var profile string
+	if strings.HasPrefix(url, "codecommit:") {
+		var err error
+		if url, profile, err = gitauth.FromGRC(ctx, url, m.Region); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := translate.RemoteHTTPS(url); err != nil {
+		return url, nil
+	}
+
+	return m.Sign(ctx, url, profile)
+}
@@ -0,0 +1,199 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package mirror
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/gittest"
+	"github.com/gembaadvantage/codecommit-sign/pkg/gitauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	repoURL = "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/"
+)
+
+// localSigner resolves CodeCommit URLs to bare repositories within a local directory,
+// nested beneath a directory named after any profile
+func localSigner(dir string) gitauth.SignFunc {
+	return func(_ context.Context, url, profile string) (string, error) {
+		return filepath.Join(dir, profile, strings.TrimPrefix(url, repoURL)), nil
+	}
+}
+
+// source creates a repository with a feature branch and tag, ready to be mirrored
+func source(t *testing.T) string {
+	t.Helper()
+
+	src := gittest.Repo(t, filepath.Join(t.TempDir(), "source"))
+	gittest.Git(t, src, "branch", "feature")
+	gittest.Git(t, src, "tag", "v1.0.0")
+
+	return src
+}
+
+func refs(t *testing.T, dir string) string {
+	t.Helper()
+	return gittest.Git(t, dir, "for-each-ref", "--format=%(refname) %(objectname)")
+}
+
+func TestSync(t *testing.T) {
+	src := source(t)
+
+	remotes := t.TempDir()
+	gittest.Git(t, "", "init", "--bare", "--quiet", filepath.Join(remotes, "target"))
+
+	m := Mirror{
+		From: src,
+		To:   repoURL + "target",
+		Dir:  filepath.Join(t.TempDir(), "mirror"),
+		Sign: localSigner(remotes),
+	}
+
+	require.NoError(t, m.Sync(context.Background()))
+	assert.Equal(t, refs(t, src), refs(t, filepath.Join(remotes, "target")))
+}
+
+func TestSync_FromGRC(t *testing.T) {
+	remotes := t.TempDir()
+	gittest.BareRepo(t, filepath.Join(remotes, "source"))
+	dst := filepath.Join(t.TempDir(), "target")
+	gittest.Git(t, "", "init", "--bare", "--quiet", dst)
+
+	m := Mirror{
+		From: "codecommit::eu-west-1://source",
+		To:   dst,
+		Dir:  filepath.Join(t.TempDir(), "mirror"),
+		Sign: localSigner(remotes),
+	}
+
+	require.NoError(t, m.Sync(context.Background()))
+	assert.Equal(t, refs(t, filepath.Join(remotes, "source")), refs(t, dst))
+}
+
+func TestSync_FromGRCNamedProfile(t *testing.T) {
+	remotes := t.TempDir()
+	gittest.BareRepo(t, filepath.Join(remotes, "profile", "source"))
+	dst := filepath.Join(t.TempDir(), "target")
+	gittest.Git(t, "", "init", "--bare", "--quiet", dst)
+
+	m := Mirror{
+		From: "codecommit::eu-west-1://profile@source",
+		To:   dst,
+		Dir:  filepath.Join(t.TempDir(), "mirror"),
+		Sign: localSigner(remotes),
+	}
+
+	require.NoError(t, m.Sync(context.Background()))
+	assert.Equal(t, refs(t, filepath.Join(remotes, "profile", "source")), refs(t, dst))
+}
+
+func TestSync_FromGRCResolvesRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	remotes := t.TempDir()
+	gittest.BareRepo(t, filepath.Join(remotes, "profile", "source"))
+	dst := filepath.Join(t.TempDir(), "target")
+	gittest.Git(t, "", "init", "--bare", "--quiet", dst)
+
+	var resolved string
+	m := Mirror{
+		From: "codecommit://profile@source",
+		To:   dst,
+		Dir:  filepath.Join(t.TempDir(), "mirror"),
+		Sign: localSigner(remotes),
+		Region: func(_ context.Context, profile string) (string, error) {
+			resolved = profile
+			return "eu-west-1", nil
+		},
+	}
+
+	require.NoError(t, m.Sync(context.Background()))
+	assert.Equal(t, "profile", resolved)
+	assert.Equal(t, refs(t, filepath.Join(remotes, "profile", "source")), refs(t, dst))
+}
+
+func TestSync_Prunes(t *testing.T) {
+	src := source(t)
+	dst := filepath.Join(t.TempDir(), "target")
+	gittest.Git(t, "", "init", "--bare", "--quiet", dst)
+
+	m := Mirror{
+		From: src,
+		To:   dst,
+		Dir:  filepath.Join(t.TempDir(), "mirror"),
+	}
+	require.NoError(t, m.Sync(context.Background()))
+
+	gittest.Git(t, src, "branch", "-D", "feature")
+	gittest.Commit(t, src, "new commit")
+
+	require.NoError(t, m.Sync(context.Background()))
+	assert.Equal(t, refs(t, src), refs(t, dst))
+}
+
+func TestSync_FilterRefs(t *testing.T) {
+	src := source(t)
+	dst := filepath.Join(t.TempDir(), "target")
+	gittest.Git(t, "", "init", "--bare", "--quiet", dst)
+
+	m := Mirror{
+		From: src,
+		To:   dst,
+		Refs: []string{"refs/heads/*"},
+		Dir:  filepath.Join(t.TempDir(), "mirror"),
+	}
+
+	require.NoError(t, m.Sync(context.Background()))
+
+	out := refs(t, dst)
+	assert.Contains(t, out, "refs/heads/main")
+	assert.Contains(t, out, "refs/heads/feature")
+	assert.NotContains(t, out, "refs/tags/v1.0.0")
+}
+
+func TestSync_SignError(t *testing.T) {
+	m := Mirror{
+		From: source(t),
+		To:   repoURL + "target",
+		Dir:  filepath.Join(t.TempDir(), "mirror"),
+		Sign: func(context.Context, string, string) (string, error) {
+			return "", errors.New("sign error")
+		},
+	}
+
+	require.EqualError(t, m.Sync(context.Background()), "sign error")
+}
+
+func TestSync_NoDir(t *testing.T) {
+	m := Mirror{From: source(t), To: t.TempDir()}
+
+	require.EqualError(t, m.Sync(context.Background()), "no mirror directory provided")
+}
@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package redact
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+)
+
+// Mask replaces any sensitive value identified within text
+const Mask = "[REDACTED]"
+
+var (
+	// Matches the userinfo of any URL targeting CodeCommit
+	userinfoRgx = regexp.MustCompile(`(https?://)[^/@\s]+@(git-codecommit(?:-fips)?\.)`)
+
+	// Matches the password of a signed CodeCommit URL, YYYYMMDDTHHMMSSZ<signature>
+	signatureRgx = regexp.MustCompile(`\b[0-9]{8}T[0-9]{6}Z[0-9a-f]{64}\b`)
+
+	// Matches both long-term (AKIA) and temporary (ASIA) AWS access key IDs
+	accessKeyRgx = regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`)
+
+	// Matches AWS session tokens, which are base64 encoded and share a common prefix
+	// depending on the version of STS that issued them. Tokens may also be URL encoded
+	sessionTokenRgx = regexp.MustCompile(`(IQoJb3JpZ2lu|FwoGZXIvYXdz|FQoGZXIvYXdz)[A-Za-z0-9/+=%]+`)
+)
+
+// String masks any CodeCommit signed URL credentials, AWS access key IDs and AWS
+// session tokens within text
+func String(s string) string {
+	s = userinfoRgx.ReplaceAllString(s, "${1}"+Mask+"@${2}")
+	s = signatureRgx.ReplaceAllString(s, Mask)
+	s = accessKeyRgx.ReplaceAllString(s, Mask)
+	return sessionTokenRgx.ReplaceAllString(s, Mask)
+}
+
+// Error wraps an error, ensuring its message is masked of any sensitive values.
+// The original error can still be retrieved through errors.Is and errors.As
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	return redactedError{err: err}
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e redactedError) Error() string {
+	return String(e.err.Error())
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// Copy masks any sensitive values from r line by line, writing the result to w
+func Copy(w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			if _, werr := io.WriteString(w, String(line)); werr != nil {
+				return werr
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package region
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+)
+
+// Resolver identifies an AWS region from a single source
+type Resolver interface {
+	// Region returns the identified region, or an empty string if the source
+	// does not provide one
+	Region(ctx context.Context) (string, error)
+
+	// Source describes where the region was identified from
+	Source() string
+}
+
+// Resolution contains the outcome of resolving a region
+type Resolution struct {
+	// Region is the resolved AWS region
+	Region string
+
+	// Source describes where the region was identified from
+	Source string
+}
+
+// Chain consults each resolver in turn, stopping at the first to identify a region
+type Chain []Resolver
+
+// Resolve returns the first region identified by the chain. An error is returned
+// if none of the resolvers can identify a region
+func (c Chain) Resolve(ctx context.Context) (Resolution, error) {
+	for _, r := range c {
+		region, err := r.Region(ctx)
+		if err != nil {
+			return Resolution{}, fmt.Errorf("resolving region from %s: %w", r.Source(), err)
+		}
+
+		if region != "" {
+			return Resolution{Region: region, Source: r.Source()}, nil
+		}
+	}
+
+	return Resolution{}, errors.New("no aws region identified")
+}
+
+// Static provides a fixed region, such as one provided through a flag
+type Static struct {
+	// Value is the region
+	Value string
+
+	// Name describes where the region came from
+	Name string
+}
+
+// Region returns the fixed region
+func (s Static) Region(context.Context) (string, error) {
+	return s.Value, nil
+}
+
+// Source describes where the region came from
+func (s Static) Source() string {
+	return s.Name
+}
+
+// Env identifies a region from an environment variable
+type Env string
+
+// Region returns the value of the environment variable
+func (e Env) Region(context.Context) (string, error) {
+	return os.Getenv(string(e)), nil
+}
+
+// Source returns the name of the environment variable
+func (e Env) Source() string {
+	return string(e)
+}
+
+// SharedConfig identifies a region from a profile within the shared AWS config files
+type SharedConfig struct {
+	// Profile is the named profile to read. If empty, the profile identified by the
+	// AWS_PROFILE environment variable is used, falling back to the default profile
+	Profile string
+
+	// ConfigFiles optionally overrides the shared config files that are read. If
+	// empty, the file identified by the AWS_CONFIG_FILE environment variable is read,
+	// falling back to ~/.aws/config
+	ConfigFiles []string
+}
+
+// Region returns the region configured against the profile. A missing profile is
+// not treated as an error
+func (s SharedConfig) Region(ctx context.Context) (string, error) {
+	cfg, err := config.LoadSharedConfigProfile(ctx, s.profile(), func(o *config.LoadSharedConfigOptions) {
+		switch {
+		case s.ConfigFiles != nil:
+			o.ConfigFiles = s.ConfigFiles
+			o.CredentialsFiles = []string{}
+		case os.Getenv("AWS_CONFIG_FILE") != "":
+			o.ConfigFiles = []string{os.Getenv("AWS_CONFIG_FILE")}
+			o.CredentialsFiles = []string{}
+		}
+	})
+	if err != nil {
+		var notExist config.SharedConfigProfileNotExistError
+		if errors.As(err, &notExist) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return cfg.Region, nil
+}
+
+// Source identifies the profile that was read
+func (s SharedConfig) Source() string {
+	return fmt.Sprintf("shared config profile %q", s.profile())
+}
+
+func (s SharedConfig) profile() string {
+	if s.Profile != "" {
+		return s.Profile
+	}
+
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+// IMDS identifies a region from the EC2 instance metadata service
+type IMDS struct {
+	// Client is used to query the instance metadata service
+	Client *imds.Client
+}
+
+// Region queries the instance metadata service for the region of the instance
+func (i IMDS) Region(ctx context.Context) (string, error) {
+	client := i.Client
+	if client == nil {
+		client = imds.New(imds.Options{})
+	}
+
+	out, err := client.GetRegion(ctx, &imds.GetRegionInput{})
+	if err != nil {
+		return "", err
+	}
+	return out.Region, nil
+}
+
+// Source describes the instance metadata service
+func (IMDS) Source() string {
+	return "ec2 instance metadata"
+}
+
+// ECS identifies a region from the ECS task metadata endpoint (version 4). The region
+// is only identified when running within an ECS task
+type ECS struct {
+	// Client is used to query the task metadata endpoint
+	Client *http.Client
+}
+
+// Region queries the ECS task metadata endpoint for the region of the running task.
+// Resolution is skipped if the ECS_CONTAINER_METADATA_URI_V4 environment variable
+// is not set
+func (e ECS) Region(ctx context.Context) (string, error) {
+	uri := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+	if uri == "" {
+		return "", nil
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri+"/task", http.NoBody)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var task struct {
+		AvailabilityZone string `json:"AvailabilityZone"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return "", err
+	}
+
+	// The region is identified by dropping the zone letter, e.g. eu-west-1a => eu-west-1
+	if len(task.AvailabilityZone) < 2 {
+		return "", nil
+	}
+	return task.AvailabilityZone[:len(task.AvailabilityZone)-1], nil
+}
+
+// Source describes the task metadata endpoint
+func (ECS) Source() string {
+	return "ecs task metadata"
+}
@@ -0,0 +1,239 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package region
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sharedConfig = `[default]
+region = eu-west-1
+
+[profile developer]
+region = eu-west-2
+
+[profile noregion]
+output = json
+`
+
+func configFile(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(sharedConfig), 0o600))
+	return path
+}
+
+type failingResolver struct{}
+
+func (failingResolver) Region(context.Context) (string, error) {
+	return "", errors.New("failed")
+}
+
+func (failingResolver) Source() string {
+	return "failing"
+}
+
+func TestChain_Resolve(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "us-east-2")
+
+	c := Chain{
+		Static{Value: "", Name: "--region flag"},
+		Env("AWS_REGION"),
+		Env("AWS_DEFAULT_REGION"),
+		SharedConfig{ConfigFiles: []string{configFile(t)}},
+	}
+
+	res, err := c.Resolve(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, Resolution{Region: "us-east-2", Source: "AWS_DEFAULT_REGION"}, res)
+}
+
+func TestChain_ResolveNoRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+
+	_, err := Chain{Env("AWS_REGION")}.Resolve(context.Background())
+
+	require.EqualError(t, err, "no aws region identified")
+}
+
+func TestChain_ResolveError(t *testing.T) {
+	_, err := Chain{failingResolver{}, Static{Value: "eu-west-1"}}.Resolve(context.Background())
+
+	require.EqualError(t, err, "resolving region from failing: failed")
+}
+
+func TestStatic(t *testing.T) {
+	s := Static{Value: "eu-west-1", Name: "--region flag"}
+
+	region, err := s.Region(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", region)
+	assert.Equal(t, "--region flag", s.Source())
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-3")
+
+	region, err := Env("AWS_DEFAULT_REGION").Region(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-3", region)
+}
+
+func TestSharedConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    string
+		envProfile string
+		region     string
+		source     string
+	}{
+		{
+			name:   "DefaultProfile",
+			region: "eu-west-1",
+			source: `shared config profile "default"`,
+		},
+		{
+			name:    "NamedProfile",
+			profile: "developer",
+			region:  "eu-west-2",
+			source:  `shared config profile "developer"`,
+		},
+		{
+			name:       "ProfileFromEnv",
+			envProfile: "developer",
+			region:     "eu-west-2",
+			source:     `shared config profile "developer"`,
+		},
+		{
+			name:    "NoRegion",
+			profile: "noregion",
+			region:  "",
+			source:  `shared config profile "noregion"`,
+		},
+		{
+			name:    "MissingProfile",
+			profile: "missing",
+			region:  "",
+			source:  `shared config profile "missing"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_PROFILE", tt.envProfile)
+			s := SharedConfig{Profile: tt.profile, ConfigFiles: []string{configFile(t)}}
+
+			region, err := s.Region(context.Background())
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.region, region)
+			assert.Equal(t, tt.source, s.Source())
+		})
+	}
+}
+
+func TestSharedConfig_ConfigFileFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		configFiles []string
+		region      string
+	}{
+		{
+			name:   "EnvConfigFile",
+			region: "eu-west-2",
+		},
+		{
+			name:        "ConfigFilesTakePrecedence",
+			configFiles: []string{filepath.Join(t.TempDir(), "missing")},
+			region:      "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_CONFIG_FILE", configFile(t))
+			s := SharedConfig{Profile: "developer", ConfigFiles: tt.configFiles}
+
+			region, err := s.Region(context.Background())
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.region, region)
+		})
+	}
+}
+
+func TestIMDS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			w.Header().Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+			w.Write([]byte("token"))
+		case "/latest/dynamic/instance-identity/document":
+			w.Write([]byte(`{"region":"ap-southeast-2","availabilityZone":"ap-southeast-2a"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	region, err := IMDS{Client: imds.New(imds.Options{Endpoint: srv.URL})}.Region(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "ap-southeast-2", region)
+}
+
+func TestECS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v4/container/task", r.URL.Path)
+		w.Write([]byte(`{"Cluster":"default","AvailabilityZone":"us-west-2b"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", srv.URL+"/v4/container")
+
+	region, err := ECS{}.Region(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", region)
+}
+
+func TestECS_NotRunningInTask(t *testing.T) {
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+
+	region, err := ECS{}.Region(context.Background())
+
+	require.NoError(t, err)
+	assert.Empty(t, region)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package repos
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/git"
+	"github.com/gembaadvantage/codecommit-sign/pkg/gitauth"
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+)
+
+// Cloner clones CodeCommit repositories into a local directory using signed URLs.
+// Any repository that has already been cloned will be fetched instead, making it
+// safe to run repeatedly against the same directory
+type Cloner struct {
+	// Dir is the directory that each repository will be cloned into
+	Dir string
+
+	// Parallel controls how many repositories are cloned at the same time
+	Parallel int
+
+	// Sign is used to generate a signed URL for each repository, using the default
+	// credentials as HTTPS URLs never name a profile
+	Sign gitauth.SignFunc
+}
+
+// Result captures the outcome of cloning an individual repository
+type Result struct {
+	// Repository contains the name of the repository
+	Repository string
+
+	// Dir contains the path to the local clone of the repository
+	Dir string
+
+	// Fetched identifies if an existing clone was fetched rather than cloned
+	Fetched bool
+
+	// Err contains any error raised while cloning the repository
+	Err error
+}
+
+// CloneAll clones or fetches each of the provided CodeCommit HTTPS URLs. A result is
+// returned for every URL, in the same order they were provided
+func (c Cloner) CloneAll(ctx context.Context, urls []string) []Result {
+	parallel := c.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]Result, len(urls))
+	sem := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = c.clone(ctx, u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (c Cloner) clone(ctx context.Context, url string) Result {
+	rem, err := translate.RemoteHTTPS(url)
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	res := Result{
+		Repository: rem.Repository,
+		Dir:        filepath.Join(c.Dir, rem.Repository),
+	}
+
+	surl, err := c.Sign(ctx, url, "")
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	if _, err := os.Stat(filepath.Join(res.Dir, ".git")); err == nil {
+		res.Fetched = true
+		_, res.Err = git.Run(ctx, res.Dir, "fetch", "--prune", "--tags", surl, "+refs/heads/*:refs/remotes/origin/*")
+		return res
+	} else if !errors.Is(err, os.ErrNotExist) {
+		res.Err = err
+		return res
+	}
+
+	// Clone from the current directory, as the target directory may not exist yet. Git
+	// creates any missing parent directories of the clone
+	if _, res.Err = git.Run(ctx, "", "clone", surl, res.Dir); res.Err != nil {
+		return res
+	}
+
+	// Ensure the signed URL isn't persisted within the git config of the cloned repository
+	_, res.Err = git.Run(ctx, res.Dir, "remote", "set-url", "origin", url)
+	return res
+}
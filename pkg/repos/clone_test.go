@@ -0,0 +1,153 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package repos
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/gittest"
+	"github.com/gembaadvantage/codecommit-sign/pkg/gitauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	repoURL = "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/"
+)
+
+// localSigner resolves CodeCommit URLs to bare repositories within a local directory
+func localSigner(dir string) gitauth.SignFunc {
+	return func(_ context.Context, url, _ string) (string, error) {
+		return filepath.Join(dir, strings.TrimPrefix(url, repoURL)), nil
+	}
+}
+
+func TestCloneAll(t *testing.T) {
+	remotes := t.TempDir()
+	gittest.BareRepo(t, filepath.Join(remotes, "service-a"))
+	gittest.BareRepo(t, filepath.Join(remotes, "service-b"))
+
+	dir := t.TempDir()
+	c := Cloner{Dir: dir, Parallel: 2, Sign: localSigner(remotes)}
+
+	res := c.CloneAll(context.Background(), []string{repoURL + "service-a", repoURL + "service-b"})
+
+	require.Len(t, res, 2)
+	for i, name := range []string{"service-a", "service-b"} {
+		require.NoError(t, res[i].Err)
+		assert.Equal(t, name, res[i].Repository)
+		assert.Equal(t, filepath.Join(dir, name), res[i].Dir)
+		assert.False(t, res[i].Fetched)
+
+		// Signed URL should never be persisted
+		assert.Equal(t, repoURL+name, gittest.Git(t, res[i].Dir, "remote", "get-url", "origin"))
+	}
+}
+
+func TestCloneAll_MissingDir(t *testing.T) {
+	remotes := t.TempDir()
+	gittest.BareRepo(t, filepath.Join(remotes, "service"))
+
+	dir := filepath.Join(t.TempDir(), "backup", "codecommit")
+	c := Cloner{Dir: dir, Sign: localSigner(remotes)}
+
+	res := c.CloneAll(context.Background(), []string{repoURL + "service"})
+
+	require.NoError(t, res[0].Err)
+	assert.DirExists(t, filepath.Join(dir, "service", ".git"))
+}
+
+func TestCloneAll_RelativeDir(t *testing.T) {
+	remotes := t.TempDir()
+	gittest.BareRepo(t, filepath.Join(remotes, "service"))
+
+	wd := t.TempDir()
+	t.Chdir(wd)
+	c := Cloner{Dir: "backup", Sign: localSigner(remotes)}
+
+	res := c.CloneAll(context.Background(), []string{repoURL + "service"})
+
+	require.NoError(t, res[0].Err)
+	assert.DirExists(t, filepath.Join(wd, "backup", "service", ".git"))
+}
+
+func TestCloneAll_FetchesExisting(t *testing.T) {
+	remotes := t.TempDir()
+	gittest.BareRepo(t, filepath.Join(remotes, "service"))
+
+	dir := t.TempDir()
+	c := Cloner{Dir: dir, Sign: localSigner(remotes)}
+
+	res := c.CloneAll(context.Background(), []string{repoURL + "service"})
+	require.NoError(t, res[0].Err)
+
+	// Push a new commit to the remote, which should be fetched
+	work := gittest.Repo(t, filepath.Join(t.TempDir(), "work"))
+	gittest.Git(t, work, "fetch", "--quiet", filepath.Join(remotes, "service"), "main")
+	gittest.Git(t, work, "reset", "--quiet", "--hard", "FETCH_HEAD")
+	hash := gittest.Commit(t, work, "new commit")
+	gittest.Git(t, work, "push", "--quiet", filepath.Join(remotes, "service"), "main")
+
+	res = c.CloneAll(context.Background(), []string{repoURL + "service"})
+
+	require.NoError(t, res[0].Err)
+	assert.True(t, res[0].Fetched)
+	assert.Equal(t, hash, gittest.Git(t, res[0].Dir, "rev-parse", "origin/main"))
+}
+
+func TestCloneAll_SignError(t *testing.T) {
+	c := Cloner{
+		Dir: t.TempDir(),
+		Sign: func(context.Context, string, string) (string, error) {
+			return "", errors.New("sign error")
+		},
+	}
+
+	res := c.CloneAll(context.Background(), []string{repoURL + "service"})
+
+	require.EqualError(t, res[0].Err, "sign error")
+	assert.NoDirExists(t, filepath.Join(c.Dir, "service"))
+}
+
+func TestCloneAll_MalformedURL(t *testing.T) {
+	c := Cloner{Dir: t.TempDir(), Sign: localSigner(t.TempDir())}
+
+	res := c.CloneAll(context.Background(), []string{"https://example.com/repository"})
+
+	require.Error(t, res[0].Err)
+}
+
+func TestCloneAll_CloneError(t *testing.T) {
+	c := Cloner{Dir: t.TempDir(), Sign: localSigner(t.TempDir())}
+
+	res := c.CloneAll(context.Background(), []string{repoURL + "missing"})
+
+	require.Error(t, res[0].Err)
+	_, err := os.Stat(filepath.Join(c.Dir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package repos
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codecommit"
+)
+
+// List enumerates the names of all CodeCommit repositories visible through the
+// provided client. Names can optionally be filtered using a glob pattern, see
+// path.Match for the supported syntax. Names are returned in alphabetical order
+func List(ctx context.Context, client codecommit.ListRepositoriesAPIClient, filter string) ([]string, error) {
+	if filter != "" {
+		// Validate the pattern upfront, as path.Match only reports a bad pattern on a match attempt
+		if _, err := path.Match(filter, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	names := []string{}
+
+	p := codecommit.NewListRepositoriesPaginator(client, &codecommit.ListRepositoriesInput{})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range page.Repositories {
+			name := aws.ToString(r.RepositoryName)
+			if filter != "" {
+				if ok, _ := path.Match(filter, name); !ok {
+					continue
+				}
+			}
+
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
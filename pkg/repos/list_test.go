@@ -0,0 +1,114 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/codecommit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubCodeCommit serves paginated ListRepositories responses, one page per
+// entry within pages
+func stubCodeCommit(t *testing.T, pages [][]string) *codecommit.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "CodeCommit_20150413.ListRepositories", r.Header.Get("X-Amz-Target"))
+
+		var in struct {
+			NextToken string `json:"nextToken"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+
+		page := 0
+		if in.NextToken != "" {
+			page = int(in.NextToken[0] - '0')
+		}
+
+		type repo struct {
+			RepositoryName string `json:"repositoryName"`
+		}
+		out := struct {
+			Repositories []repo `json:"repositories"`
+			NextToken    string `json:"nextToken,omitempty"`
+		}{}
+
+		for _, name := range pages[page] {
+			out.Repositories = append(out.Repositories, repo{RepositoryName: name})
+		}
+		if page+1 < len(pages) {
+			out.NextToken = string(rune('0' + page + 1))
+		}
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+
+	return codecommit.New(codecommit.Options{
+		Region:       "eu-west-1",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("ACCESS_KEY_ID", "SECRET_ACCESS_KEY", ""),
+	})
+}
+
+func TestList(t *testing.T) {
+	client := stubCodeCommit(t, [][]string{
+		{"service-b", "library"},
+		{"service-a"},
+	})
+
+	names, err := List(context.Background(), client, "")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"library", "service-a", "service-b"}, names)
+}
+
+func TestList_Filter(t *testing.T) {
+	client := stubCodeCommit(t, [][]string{
+		{"service-b", "library"},
+		{"service-a"},
+	})
+
+	names, err := List(context.Background(), client, "service-*")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"service-a", "service-b"}, names)
+}
+
+func TestList_MalformedFilter(t *testing.T) {
+	client := stubCodeCommit(t, [][]string{{"service"}})
+
+	_, err := List(context.Background(), client, "[service")
+
+	require.Error(t, err)
+}
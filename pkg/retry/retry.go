@@ -0,0 +1,174 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+const (
+	// DefaultMaxAttempts is the number of times an operation is attempted, including
+	// the initial attempt, if a policy does not set one
+	DefaultMaxAttempts = 3
+
+	// DefaultBaseDelay is the delay before the first retry, prior to any jitter
+	DefaultBaseDelay = 200 * time.Millisecond
+
+	// DefaultMaxBackoff is the upper bound of any single delay between attempts
+	DefaultMaxBackoff = 20 * time.Second
+)
+
+// Clock controls the passage of time between attempts, allowing tests to retry
+// without waiting
+type Clock interface {
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Policy retries an operation with jittered exponential backoff. Each delay is
+// chosen at random between zero and the exponential backoff for that attempt,
+// capped at MaxBackoff, spreading out retries from many concurrent clients
+type Policy struct {
+	// MaxAttempts is the number of times an operation is attempted, including the
+	// initial attempt. A value of 1 disables retries
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry, doubling on each subsequent
+	// retry before any jitter is applied
+	BaseDelay time.Duration
+
+	// MaxBackoff caps the delay between any two attempts
+	MaxBackoff time.Duration
+
+	// Clock controls waiting between attempts, defaulting to the system clock
+	Clock Clock
+
+	// Rand generates the jitter applied to each delay, returning a value within
+	// [0.0, 1.0). Defaults to a pseudo-random source
+	Rand func() float64
+
+	// OnRetry, if set, is called before waiting to retry a failed attempt
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// Do calls fn until it succeeds, returns an error that cannot be retried, or the
+// maximum number of attempts is reached. Waiting between attempts is aborted if
+// the context is cancelled
+func (p Policy) Do(ctx context.Context, fn func(context.Context) error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	clock := p.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= maxAttempts || !Retryable(err) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, delay, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-clock.After(delay):
+		}
+	}
+}
+
+// Calculates the jittered delay before retrying the given attempt
+func (p Policy) backoff(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = DefaultBaseDelay
+	}
+
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	// Stop doubling once the cap is reached, guarding against overflow
+	delay := base
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxBackoff)
+
+	jitter := p.Rand
+	if jitter == nil {
+		jitter = rand.Float64
+	}
+	return time.Duration(jitter() * float64(delay))
+}
+
+// Retryable identifies if an error is transient and worth retrying, such as a
+// throttled request, a connection failure or a 5xx response. Any other error,
+// including denied access, invalid tokens or a cancelled context, is permanent
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	return awsretry.IsErrorRetryables(awsretry.DefaultRetryables).IsErrorRetryable(err).Bool()
+}
+
+// Provider retries the retrieval of credentials from an underlying provider,
+// based on its policy. Ideal for absorbing throttling from STS, SSO, IMDS or a
+// credential process when many clients retrieve credentials at once
+type Provider struct {
+	Provider aws.CredentialsProvider
+	Policy   Policy
+}
+
+// Retrieve retrieves credentials from the underlying provider, retrying on any
+// transient error
+func (p Provider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	var creds aws.Credentials
+	err := p.Policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		creds, err = p.Provider.Retrieve(ctx)
+		return err
+	})
+
+	return creds, err
+}
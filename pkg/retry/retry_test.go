@@ -0,0 +1,269 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock records every requested delay and never waits
+type fakeClock struct {
+	delays []time.Duration
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+// blockingClock never allows a retry to proceed
+type blockingClock struct{}
+
+func (blockingClock) After(time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+var throttled = &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"}
+
+func maxJitter() float64 { return 1.0 }
+
+func TestPolicy_Do(t *testing.T) {
+	clock := &fakeClock{}
+	p := Policy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, Clock: clock, Rand: maxJitter}
+
+	calls := 0
+	err := p.Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return throttled
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, clock.delays)
+}
+
+func TestPolicy_Do_MaxAttempts(t *testing.T) {
+	clock := &fakeClock{}
+	p := Policy{MaxAttempts: 4, Clock: clock, Rand: maxJitter}
+
+	calls := 0
+	err := p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return throttled
+	})
+
+	require.ErrorIs(t, err, throttled)
+	assert.Equal(t, 4, calls)
+	assert.Len(t, clock.delays, 3)
+}
+
+func TestPolicy_Do_SingleAttempt(t *testing.T) {
+	clock := &fakeClock{}
+	p := Policy{MaxAttempts: 1, Clock: clock}
+
+	calls := 0
+	err := p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return throttled
+	})
+
+	require.ErrorIs(t, err, throttled)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, clock.delays)
+}
+
+func TestPolicy_Do_PermanentError(t *testing.T) {
+	clock := &fakeClock{}
+	p := Policy{MaxAttempts: 5, Clock: clock}
+
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized"}
+	calls := 0
+	err := p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return denied
+	})
+
+	require.ErrorIs(t, err, denied)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, clock.delays)
+}
+
+func TestPolicy_Do_CancelledWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Policy{
+		MaxAttempts: 5,
+		Clock:       blockingClock{},
+		OnRetry: func(int, time.Duration, error) {
+			cancel()
+		},
+	}
+
+	err := p.Do(ctx, func(context.Context) error {
+		return throttled
+	})
+
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, err, throttled)
+}
+
+func TestPolicy_Do_OnRetry(t *testing.T) {
+	type retry struct {
+		attempt int
+		delay   time.Duration
+	}
+
+	var retries []retry
+	p := Policy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		Clock:       &fakeClock{},
+		Rand:        func() float64 { return 0.5 },
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			assert.ErrorIs(t, err, throttled)
+			retries = append(retries, retry{attempt: attempt, delay: delay})
+		},
+	}
+
+	p.Do(context.Background(), func(context.Context) error {
+		return throttled
+	})
+
+	assert.Equal(t, []retry{{attempt: 1, delay: 500 * time.Millisecond}, {attempt: 2, delay: time.Second}}, retries)
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxBackoff: 10 * time.Second, Rand: maxJitter}
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{attempt: 1, delay: time.Second},
+		{attempt: 2, delay: 2 * time.Second},
+		{attempt: 3, delay: 4 * time.Second},
+		{attempt: 4, delay: 8 * time.Second},
+		{attempt: 5, delay: 10 * time.Second},
+		{attempt: 100, delay: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("Attempt%d", tt.attempt), func(t *testing.T) {
+			assert.Equal(t, tt.delay, p.backoff(tt.attempt))
+		})
+	}
+}
+
+func TestPolicy_Backoff_Jitter(t *testing.T) {
+	p := Policy{BaseDelay: time.Second}
+
+	for i := 0; i < 100; i++ {
+		d := p.backoff(3)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, 4*time.Second)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "Throttling", err: throttled, retryable: true},
+		{name: "ThrottlingException", err: &smithy.GenericAPIError{Code: "ThrottlingException"}, retryable: true},
+		{name: "RequestTimeout", err: &smithy.GenericAPIError{Code: "RequestTimeout"}, retryable: true},
+		{name: "Wrapped", err: fmt.Errorf("failed to refresh cached credentials, %w", throttled), retryable: true},
+		{name: "ConnectionRefused", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, retryable: true},
+		{name: "ServiceUnavailable", err: &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: httpResponse(503)}, Err: errors.New("unavailable")}, retryable: true},
+		{name: "AccessDenied", err: &smithy.GenericAPIError{Code: "AccessDenied"}},
+		{name: "ExpiredToken", err: &smithy.GenericAPIError{Code: "ExpiredTokenException"}},
+		{name: "Unknown", err: errors.New("no credentials")},
+		{name: "Canceled", err: context.Canceled},
+		{name: "DeadlineExceeded", err: fmt.Errorf("request canceled, %w", context.DeadlineExceeded)},
+		{name: "Nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.retryable, Retryable(tt.err))
+		})
+	}
+}
+
+func TestProvider_Retrieve(t *testing.T) {
+	calls := 0
+	p := Provider{
+		Provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			calls++
+			if calls == 1 {
+				return aws.Credentials{}, throttled
+			}
+			return aws.Credentials{AccessKeyID: "ACCESS_KEY_ID", Source: "fake"}, nil
+		}),
+		Policy: Policy{Clock: &fakeClock{}},
+	}
+
+	creds, err := p.Retrieve(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, "ACCESS_KEY_ID", creds.AccessKeyID)
+	assert.Equal(t, 2, calls)
+}
+
+func TestProvider_Retrieve_PermanentError(t *testing.T) {
+	calls := 0
+	p := Provider{
+		Provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			calls++
+			return aws.Credentials{}, errors.New("failed to find profile")
+		}),
+		Policy: Policy{Clock: &fakeClock{}},
+	}
+
+	_, err := p.Retrieve(context.Background())
+
+	require.EqualError(t, err, "failed to find profile")
+	assert.Equal(t, 1, calls)
+}
+
+func httpResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status}
+}
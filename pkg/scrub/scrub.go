@@ -0,0 +1,136 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package scrub
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/git"
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+)
+
+var (
+	// Matches the userinfo of a signed CodeCommit URL, AccessKeyID%SessionToken:YYYYMMDDTHHMMSSZ<signature>,
+	// where the username is URL encoded
+	signedRgx = regexp.MustCompile(`^https://[^:@/]+%25[^:@/]*:[0-9]{8}T[0-9]{6}Z[0-9a-f]{64}@git-codecommit(-fips)?\.`)
+)
+
+// Change describes a git remote that was rewritten to remove its signed credentials
+type Change struct {
+	// Repo is the path to the repository containing the remote
+	Repo string
+
+	// Key is the git config key of the remote URL, such as remote.origin.url
+	Key string
+
+	// URL is the unsigned URL now assigned to the remote
+	URL string
+}
+
+// IsSigned identifies if a URL is a CodeCommit URL containing signed credentials
+func IsSigned(url string) bool {
+	return signedRgx.MatchString(url)
+}
+
+// Unsigned strips signed credentials from a CodeCommit HTTPS URL, returning either
+// the unsigned HTTPS or GRC form of the URL
+func Unsigned(url string, grc bool) (string, error) {
+	if grc {
+		return translate.ToGRC(url)
+	}
+
+	rem, err := translate.RemoteHTTPS(url)
+	if err != nil {
+		return "", err
+	}
+	return translate.ToHTTPS(rem)
+}
+
+// Repo rewrites every remote within a git repository whose URL contains signed
+// CodeCommit credentials, returning details of each change made
+func Repo(ctx context.Context, dir string, grc bool) ([]Change, error) {
+	out, err := git.Run(ctx, dir, "config", "--local", "--get-regexp", `^remote\..*\.(url|pushurl)$`)
+	if err != nil {
+		// git exits with a non-zero code when no remotes match
+		if _, rerr := git.Run(ctx, dir, "rev-parse", "--git-dir"); rerr != nil {
+			return nil, rerr
+		}
+		return []Change{}, nil
+	}
+
+	changes := []Change{}
+	for _, line := range strings.Split(out, "\n") {
+		key, url, ok := strings.Cut(line, " ")
+		if !ok || !IsSigned(url) {
+			continue
+		}
+
+		uurl, err := Unsigned(url, grc)
+		if err != nil {
+			return changes, err
+		}
+
+		// Only replace the matching value, as a remote can be configured with multiple URLs
+		if _, err := git.Run(ctx, dir, "config", "--local", "--replace-all", key, uurl, "^"+regexp.QuoteMeta(url)+"$"); err != nil {
+			return changes, err
+		}
+
+		changes = append(changes, Change{Repo: dir, Key: key, URL: uurl})
+	}
+
+	return changes, nil
+}
+
+// All searches a directory tree for git repositories, including submodules, and
+// rewrites any remote containing signed CodeCommit credentials
+func All(ctx context.Context, root string, grc bool) ([]Change, error) {
+	changes := []Change{}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Name() != ".git" {
+			return nil
+		}
+
+		repo := filepath.Dir(path)
+		rc, err := Repo(ctx, repo, grc)
+		changes = append(changes, rc...)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+
+	return changes, err
+}
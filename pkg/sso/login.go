@@ -0,0 +1,192 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sso
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+const (
+	deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// The default delay between polling for a token, and the increase applied each
+	// time the client is asked to slow down
+	defaultInterval = 5 * time.Second
+)
+
+// OIDCClient provides the operations needed to log in through the device
+// authorization flow
+type OIDCClient interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
+// Authorization contains the details a user needs to approve a login from their browser
+type Authorization struct {
+	// VerificationURI is the page where the user code is entered
+	VerificationURI string
+
+	// VerificationURIComplete is the verification page with the user code pre-filled
+	VerificationURIComplete string
+
+	// UserCode must match the code displayed within the browser
+	UserCode string
+
+	// ExpiresAt is the time by which the login must be approved
+	ExpiresAt time.Time
+}
+
+// Login performs the OAuth 2.0 device authorization flow against IAM Identity
+// Center, caching the resulting token for the session
+type Login struct {
+	// Client used to call the IAM Identity Center OIDC endpoints
+	Client OIDCClient
+
+	// Session to log in to
+	Session Session
+
+	// ClientName is registered with IAM Identity Center and shown within the browser
+	ClientName string
+
+	// Prompt is called with the details the user needs to approve the login
+	Prompt func(Authorization)
+
+	// After controls the delay between polling for a token, defaulting to time.After
+	After func(time.Duration) <-chan time.Time
+
+	// Now returns the current time, defaulting to time.Now
+	Now func() time.Time
+}
+
+// Run logs in to the session, blocking until the user approves the login, the
+// authorization expires or the context is cancelled
+func (l Login) Run(ctx context.Context) (Token, error) {
+	after, now := l.After, l.Now
+	if after == nil {
+		after = time.After
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	name := l.ClientName
+	if name == "" {
+		name = "codecommit-sign"
+	}
+
+	// Access to accounts is only granted to tokens from an sso-session if explicitly requested
+	var scopes []string
+	if l.Session.Name != "" {
+		scopes = []string{"sso:account:access"}
+	}
+
+	reg, err := l.Client.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String(name),
+		ClientType: aws.String("public"),
+		Scopes:     scopes,
+	})
+	if err != nil {
+		return Token{}, err
+	}
+
+	auth, err := l.Client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     reg.ClientId,
+		ClientSecret: reg.ClientSecret,
+		StartUrl:     aws.String(l.Session.StartURL),
+	})
+	if err != nil {
+		return Token{}, err
+	}
+
+	expiresAt := now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	if l.Prompt != nil {
+		l.Prompt(Authorization{
+			VerificationURI:         aws.ToString(auth.VerificationUri),
+			VerificationURIComplete: aws.ToString(auth.VerificationUriComplete),
+			UserCode:                aws.ToString(auth.UserCode),
+			ExpiresAt:               expiresAt,
+		})
+	}
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	for {
+		tok, err := l.Client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     reg.ClientId,
+			ClientSecret: reg.ClientSecret,
+			DeviceCode:   auth.DeviceCode,
+			GrantType:    aws.String(deviceCodeGrant),
+		})
+		if err == nil {
+			t := Token{
+				AccessToken:  aws.ToString(tok.AccessToken),
+				ExpiresAt:    now().Add(time.Duration(tok.ExpiresIn) * time.Second).UTC().Format(time.RFC3339),
+				RefreshToken: aws.ToString(tok.RefreshToken),
+				Region:       l.Session.Region,
+				StartURL:     l.Session.StartURL,
+			}
+
+			// Client details are only needed by the SDKs to refresh tokens from an sso-session
+			if l.Session.Name != "" {
+				t.ClientID = aws.ToString(reg.ClientId)
+				t.ClientSecret = aws.ToString(reg.ClientSecret)
+				t.RegistrationExpiresAt = time.Unix(reg.ClientSecretExpiresAt, 0).UTC().Format(time.RFC3339)
+			}
+
+			return t, l.Session.Store(t)
+		}
+
+		var (
+			pending  *types.AuthorizationPendingException
+			slowDown *types.SlowDownException
+		)
+
+		switch {
+		case errors.As(err, &pending):
+		case errors.As(err, &slowDown):
+			interval += defaultInterval
+		default:
+			return Token{}, err
+		}
+
+		if !now().Before(expiresAt) {
+			return Token{}, errors.New("aws sso login was not approved before the authorization expired")
+		}
+
+		select {
+		case <-ctx.Done():
+			return Token{}, ctx.Err()
+		case <-after(interval):
+		}
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubOIDC emulates the IAM Identity Center OIDC endpoints used by the device
+// authorization flow. Each call to create a token is answered by the next error
+// code, until none remain and a token is issued
+type stubOIDC struct {
+	mu          sync.Mutex
+	tokenErrors []string
+	tokenCalls  int
+	registered  map[string]any
+	started     map[string]any
+	tokenInput  map[string]any
+}
+
+func (s *stubOIDC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var in map[string]any
+	json.NewDecoder(r.Body).Decode(&in)
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/client/register":
+		s.registered = in
+		json.NewEncoder(w).Encode(map[string]any{
+			"clientId":              "CLIENT_ID",
+			"clientSecret":          "CLIENT_SECRET",
+			"clientIdIssuedAt":      1709294400,
+			"clientSecretExpiresAt": 1717070400,
+		})
+	case "/device_authorization":
+		s.started = in
+		json.NewEncoder(w).Encode(map[string]any{
+			"deviceCode":              "DEVICE_CODE",
+			"userCode":                "ABCD-EFGH",
+			"verificationUri":         "https://device.sso.eu-west-2.amazonaws.com/",
+			"verificationUriComplete": "https://device.sso.eu-west-2.amazonaws.com/?user_code=ABCD-EFGH",
+			"expiresIn":               600,
+			"interval":                1,
+		})
+	case "/token":
+		s.tokenInput = in
+		s.tokenCalls++
+		if len(s.tokenErrors) > 0 {
+			code := s.tokenErrors[0]
+			s.tokenErrors = s.tokenErrors[1:]
+
+			w.Header().Set("X-Amzn-ErrorType", code)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": code})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"accessToken":  "ACCESS_TOKEN",
+			"expiresIn":    28800,
+			"refreshToken": "REFRESH_TOKEN",
+			"tokenType":    "Bearer",
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func stubClient(t *testing.T, stub *stubOIDC) *ssooidc.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	return ssooidc.New(ssooidc.Options{
+		Region:       "eu-west-2",
+		BaseEndpoint: aws.String(srv.URL),
+	})
+}
+
+// fakeClock records every requested delay and never waits
+type fakeClock struct {
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestLogin_Run(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	stub := &stubOIDC{tokenErrors: []string{"AuthorizationPendingException", "SlowDownException", "AuthorizationPendingException"}}
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	sess := Session{Profile: "session", Name: "corp", StartURL: "https://corp.awsapps.com/start", Region: "eu-west-2"}
+
+	var auth Authorization
+	l := Login{
+		Client:  stubClient(t, stub),
+		Session: sess,
+		Prompt:  func(a Authorization) { auth = a },
+		After:   clock.After,
+		Now:     clock.Now,
+	}
+
+	tok, err := l.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, Authorization{
+		VerificationURI:         "https://device.sso.eu-west-2.amazonaws.com/",
+		VerificationURIComplete: "https://device.sso.eu-west-2.amazonaws.com/?user_code=ABCD-EFGH",
+		UserCode:                "ABCD-EFGH",
+		ExpiresAt:               time.Date(2024, 3, 1, 12, 10, 0, 0, time.UTC),
+	}, auth)
+
+	assert.Equal(t, "codecommit-sign", stub.registered["clientName"])
+	assert.Equal(t, "public", stub.registered["clientType"])
+	assert.Equal(t, []any{"sso:account:access"}, stub.registered["scopes"])
+	assert.Equal(t, "https://corp.awsapps.com/start", stub.started["startUrl"])
+	assert.Equal(t, "DEVICE_CODE", stub.tokenInput["deviceCode"])
+	assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", stub.tokenInput["grantType"])
+
+	// Polling slows down when requested
+	assert.Equal(t, 4, stub.tokenCalls)
+	assert.Equal(t, []time.Duration{time.Second, 6 * time.Second, 6 * time.Second}, clock.delays)
+
+	assert.Equal(t, Token{
+		AccessToken:           "ACCESS_TOKEN",
+		ExpiresAt:             "2024-03-01T20:00:13Z",
+		RefreshToken:          "REFRESH_TOKEN",
+		ClientID:              "CLIENT_ID",
+		ClientSecret:          "CLIENT_SECRET",
+		RegistrationExpiresAt: "2024-05-30T12:00:00Z",
+		Region:                "eu-west-2",
+		StartURL:              "https://corp.awsapps.com/start",
+	}, tok)
+
+	cached, err := sess.Token()
+	require.NoError(t, err)
+	assert.Equal(t, tok, cached)
+}
+
+func TestLogin_Run_Legacy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	stub := &stubOIDC{}
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	sess := Session{Profile: "legacy", StartURL: "https://legacy.awsapps.com/start", Region: "us-east-1"}
+
+	l := Login{
+		Client:  stubClient(t, stub),
+		Session: sess,
+		After:   clock.After,
+		Now:     clock.Now,
+	}
+
+	tok, err := l.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.NotContains(t, stub.registered, "scopes")
+	assert.Empty(t, tok.ClientID)
+	assert.Empty(t, tok.ClientSecret)
+	require.NoError(t, sess.Status(clock.Now()))
+}
+
+func TestLogin_Run_Denied(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	stub := &stubOIDC{tokenErrors: []string{"AccessDeniedException"}}
+	l := Login{
+		Client:  stubClient(t, stub),
+		Session: Session{Name: "corp", StartURL: "https://corp.awsapps.com/start"},
+		After:   (&fakeClock{}).After,
+	}
+
+	_, err := l.Run(context.Background())
+
+	require.Error(t, err)
+	assert.Equal(t, 1, stub.tokenCalls)
+	assert.ErrorIs(t, l.Session.Status(time.Now()), ErrNotLoggedIn)
+}
+
+func TestLogin_Run_AuthorizationExpired(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	pending := make([]string, 1000)
+	for i := range pending {
+		pending[i] = "AuthorizationPendingException"
+	}
+
+	stub := &stubOIDC{tokenErrors: pending}
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	l := Login{
+		Client:  stubClient(t, stub),
+		Session: Session{Name: "corp", StartURL: "https://corp.awsapps.com/start"},
+		After:   clock.After,
+		Now:     clock.Now,
+	}
+
+	_, err := l.Run(context.Background())
+
+	require.EqualError(t, err, "aws sso login was not approved before the authorization expired")
+	assert.Equal(t, 601, stub.tokenCalls)
+}
+
+func TestLogin_Run_Cancelled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stub := &stubOIDC{tokenErrors: []string{"AuthorizationPendingException"}}
+	l := Login{
+		Client:  stubClient(t, stub),
+		Session: Session{Name: "corp", StartURL: "https://corp.awsapps.com/start"},
+		Prompt:  func(Authorization) { cancel() },
+		After:   func(time.Duration) <-chan time.Time { return make(chan time.Time) },
+	}
+
+	_, err := l.Run(ctx)
+
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,211 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+)
+
+var (
+	// ErrNotLoggedIn is returned when no token has been cached for an SSO session
+	ErrNotLoggedIn = errors.New("no cached aws sso token")
+
+	// ErrTokenExpired is returned when the cached token of an SSO session has expired
+	ErrTokenExpired = errors.New("cached aws sso token has expired")
+)
+
+// Session describes how a named profile authenticates through AWS IAM Identity
+// Center (SSO)
+type Session struct {
+	// Profile is the named profile configured to use SSO
+	Profile string
+
+	// Name of the sso-session section referenced by the profile. Empty if the
+	// profile uses the legacy sso_start_url configuration
+	Name string
+
+	// StartURL is the AWS access portal URL used to log in
+	StartURL string
+
+	// Region hosting IAM Identity Center
+	Region string
+}
+
+// Token is an SSO access token, cached using the same format as the AWS CLI and SDKs
+type Token struct {
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+	ClientID              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	Region                string `json:"region,omitempty"`
+	StartURL              string `json:"startUrl,omitempty"`
+}
+
+// Expired identifies if the token has expired at the given time. A token with an
+// unreadable expiry is treated as expired
+func (t Token) Expired(now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339, t.ExpiresAt)
+	if err != nil {
+		return true
+	}
+	return !now.Before(expiresAt)
+}
+
+// Lookup identifies if a named profile within the shared AWS config files is
+// configured to use SSO, through either an sso_session or sso_start_url. If the
+// profile is empty, the profile identified by the AWS_PROFILE environment variable
+// is used, falling back to the default profile. The config files can optionally be
+// overridden, otherwise the file identified by the AWS_CONFIG_FILE environment
+// variable is read, falling back to ~/.aws/config
+func Lookup(ctx context.Context, profile string, configFiles []string) (Session, bool, error) {
+	if profile == "" {
+		if profile = os.Getenv("AWS_PROFILE"); profile == "" {
+			profile = "default"
+		}
+	}
+
+	cfg, err := config.LoadSharedConfigProfile(ctx, profile, func(o *config.LoadSharedConfigOptions) {
+		switch {
+		case configFiles != nil:
+			o.ConfigFiles = configFiles
+			o.CredentialsFiles = []string{}
+		case os.Getenv("AWS_CONFIG_FILE") != "":
+			o.ConfigFiles = []string{os.Getenv("AWS_CONFIG_FILE")}
+			o.CredentialsFiles = []string{}
+		}
+	})
+	if err != nil {
+		return Session{}, false, err
+	}
+
+	sess := Session{
+		Profile:  profile,
+		StartURL: cfg.SSOStartURL,
+		Region:   cfg.SSORegion,
+	}
+
+	if cfg.SSOSession != nil {
+		sess.Name = cfg.SSOSession.Name
+		sess.StartURL = cfg.SSOSession.SSOStartURL
+		sess.Region = cfg.SSOSession.SSORegion
+	}
+
+	return sess, sess.StartURL != "", nil
+}
+
+// CacheFile returns the path to the file that caches the token for the session
+func (s Session) CacheFile() (string, error) {
+	// Tokens are keyed by the session name, or the start URL for legacy profiles
+	key := s.Name
+	if key == "" {
+		key = s.StartURL
+	}
+
+	return ssocreds.StandardCachedTokenFilepath(key)
+}
+
+// Token reads the cached token for the session
+func (s Session) Token() (Token, error) {
+	path, err := s.CacheFile()
+	if err != nil {
+		return Token{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Token{}, ErrNotLoggedIn
+		}
+		return Token{}, err
+	}
+
+	var tok Token
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return Token{}, fmt.Errorf("malformed aws sso token cache %s: %w", path, err)
+	}
+
+	if tok.AccessToken == "" {
+		return Token{}, ErrNotLoggedIn
+	}
+	return tok, nil
+}
+
+// Status identifies if the session has a valid cached token at the given time,
+// returning either ErrNotLoggedIn or ErrTokenExpired if it does not
+func (s Session) Status(now time.Time) error {
+	tok, err := s.Token()
+	if err != nil {
+		return err
+	}
+
+	if tok.Expired(now) {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// Store caches a token for the session, where it can be read by the AWS CLI and SDKs
+func (s Session) Store(tok Token) error {
+	path, err := s.CacheFile()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+
+	// Write atomically, ensuring a partially written token is never read
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
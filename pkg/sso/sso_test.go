@@ -0,0 +1,234 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sso
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ssoConfig = `[default]
+region = eu-west-1
+
+[profile session]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Developer
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = Developer
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-west-2
+`
+
+func configFile(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(ssoConfig), 0o600))
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		session Session
+	}{
+		{
+			name:    "SSOSession",
+			profile: "session",
+			session: Session{Profile: "session", Name: "corp", StartURL: "https://corp.awsapps.com/start", Region: "eu-west-2"},
+		},
+		{
+			name:    "Legacy",
+			profile: "legacy",
+			session: Session{Profile: "legacy", StartURL: "https://legacy.awsapps.com/start", Region: "us-east-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, ok, err := Lookup(context.Background(), tt.profile, []string{configFile(t)})
+
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, tt.session, sess)
+		})
+	}
+}
+
+func TestLookup_EnvProfile(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "session")
+
+	sess, ok, err := Lookup(context.Background(), "", []string{configFile(t)})
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "session", sess.Profile)
+}
+
+func TestLookup_ConfigFileEnv(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_CONFIG_FILE", configFile(t))
+
+	sess, ok, err := Lookup(context.Background(), "session", nil)
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "https://corp.awsapps.com/start", sess.StartURL)
+}
+
+func TestLookup_NotSSO(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "")
+
+	sess, ok, err := Lookup(context.Background(), "", []string{configFile(t)})
+
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "default", sess.Profile)
+}
+
+func TestLookup_MissingProfile(t *testing.T) {
+	_, _, err := Lookup(context.Background(), "missing", []string{configFile(t)})
+
+	require.Error(t, err)
+}
+
+func TestSession_CacheFile(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+
+	tests := []struct {
+		name    string
+		session Session
+		file    string
+	}{
+		{
+			name:    "SSOSession",
+			session: Session{Name: "corp", StartURL: "https://corp.awsapps.com/start"},
+			// sha1("corp")
+			file: "/home/user/.aws/sso/cache/ee0bfd2552fbd840c02cc48b6e823320543c450f.json",
+		},
+		{
+			name:    "Legacy",
+			session: Session{StartURL: "https://legacy.awsapps.com/start"},
+			// sha1("https://legacy.awsapps.com/start")
+			file: "/home/user/.aws/sso/cache/79e435d7a515078e81c9dffc35f38d5687ebd3a7.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := tt.session.CacheFile()
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.file, path)
+		})
+	}
+}
+
+func TestSession_Status(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		token *Token
+		err   error
+	}{
+		{
+			name:  "Valid",
+			token: &Token{AccessToken: "token", ExpiresAt: "2024-03-01T20:00:00Z"},
+		},
+		{
+			name:  "Expired",
+			token: &Token{AccessToken: "token", ExpiresAt: "2024-03-01T11:59:59Z"},
+			err:   ErrTokenExpired,
+		},
+		{
+			name:  "MalformedExpiry",
+			token: &Token{AccessToken: "token", ExpiresAt: "tomorrow"},
+			err:   ErrTokenExpired,
+		},
+		{
+			name:  "NoAccessToken",
+			token: &Token{ExpiresAt: "2024-03-01T20:00:00Z"},
+			err:   ErrNotLoggedIn,
+		},
+		{
+			name: "NotLoggedIn",
+			err:  ErrNotLoggedIn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+
+			sess := Session{Name: "corp", StartURL: "https://corp.awsapps.com/start"}
+			if tt.token != nil {
+				require.NoError(t, sess.Store(*tt.token))
+			}
+
+			err := sess.Status(now)
+			if tt.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSession_Store(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	sess := Session{Name: "corp", StartURL: "https://corp.awsapps.com/start"}
+	tok := Token{
+		AccessToken:  "token",
+		ExpiresAt:    "2024-03-01T20:00:00Z",
+		RefreshToken: "refresh",
+		Region:       "eu-west-2",
+		StartURL:     "https://corp.awsapps.com/start",
+	}
+	require.NoError(t, sess.Store(tok))
+
+	path, _ := sess.CacheFile()
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	read, err := sess.Token()
+	require.NoError(t, err)
+	assert.Equal(t, tok, read)
+}
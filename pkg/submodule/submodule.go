@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package submodule
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/git"
+)
+
+// Submodule contains details of a submodule declared within a .gitmodules file
+type Submodule struct {
+	// Name uniquely identifies the submodule
+	Name string
+
+	// Path is the location of the submodule, relative to the root of its superproject
+	Path string
+
+	// URL is the location of the repository backing the submodule
+	URL string
+}
+
+// List reads every submodule declared within the .gitmodules file of a repository.
+// Submodules are returned in the order they are declared. If the repository
+// contains no .gitmodules file, an empty list is returned
+func List(ctx context.Context, dir string) ([]Submodule, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".gitmodules")); errors.Is(err, os.ErrNotExist) {
+		return []Submodule{}, nil
+	}
+
+	out, err := git.Run(ctx, dir, "config", "--file", ".gitmodules", "--get-regexp", `^submodule\..*\.(path|url)$`)
+	if err != nil {
+		// git exits with a code of 1 when no keys match, any other failure is reported
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return []Submodule{}, nil
+		}
+		return nil, err
+	}
+
+	subs := []Submodule{}
+	index := map[string]int{}
+
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+
+		// Names can contain dots, so are identified by trimming the known prefix and suffix
+		name := strings.TrimPrefix(key, "submodule.")
+		field := name[strings.LastIndex(name, ".")+1:]
+		name = strings.TrimSuffix(name, "."+field)
+
+		i, ok := index[name]
+		if !ok {
+			subs = append(subs, Submodule{Name: name})
+			i = len(subs) - 1
+			index[name] = i
+		}
+
+		switch field {
+		case "path":
+			subs[i].Path = value
+		case "url":
+			subs[i].URL = value
+		}
+	}
+
+	return subs, nil
+}
+
+// SetURL updates the URL of a submodule within the .gitmodules file of a repository
+func SetURL(ctx context.Context, dir, name, url string) error {
+	_, err := git.Run(ctx, dir, "config", "--file", ".gitmodules", "submodule."+name+".url", url)
+	return err
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package submodule
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/gittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gitmodules = `[submodule "library"]
+	path = lib/library
+	url = codecommit::eu-west-1://library
+[submodule "vendor.tools"]
+	path = vendor/tools
+	url = https://github.com/org/tools.git
+`
+
+func TestList(t *testing.T) {
+	dir := gittest.Repo(t, t.TempDir())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(gitmodules), 0o644))
+
+	subs, err := List(context.Background(), dir)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Submodule{
+		{Name: "library", Path: "lib/library", URL: "codecommit::eu-west-1://library"},
+		{Name: "vendor.tools", Path: "vendor/tools", URL: "https://github.com/org/tools.git"},
+	}, subs)
+}
+
+func TestList_NoGitmodules(t *testing.T) {
+	dir := gittest.Repo(t, t.TempDir())
+
+	subs, err := List(context.Background(), dir)
+
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestList_NoSubmodules(t *testing.T) {
+	dir := gittest.Repo(t, t.TempDir())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte("[core]\n\tbare = false\n"), 0o644))
+
+	subs, err := List(context.Background(), dir)
+
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestList_MalformedGitmodules(t *testing.T) {
+	dir := gittest.Repo(t, t.TempDir())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte("[submodule \"library\"\n\tpath = lib\n"), 0o644))
+
+	_, err := List(context.Background(), dir)
+
+	require.Error(t, err)
+}
+
+func TestSetURL(t *testing.T) {
+	dir := gittest.Repo(t, t.TempDir())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(gitmodules), 0o644))
+
+	err := SetURL(context.Background(), dir, "vendor.tools", "https://github.com/org/renamed.git")
+	require.NoError(t, err)
+
+	subs, err := List(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/org/renamed.git", subs[1].URL)
+	assert.Equal(t, "codecommit::eu-west-1://library", subs[0].URL)
+}
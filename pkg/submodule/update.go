@@ -0,0 +1,155 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package submodule
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/git"
+	"github.com/gembaadvantage/codecommit-sign/pkg/gitauth"
+	"github.com/gembaadvantage/codecommit-sign/pkg/redact"
+	"github.com/gembaadvantage/codecommit-sign/pkg/translate"
+)
+
+// Updater initialises and updates the submodules of a repository, passing transient
+// credentials to git for every CodeCommit submodule. Each submodule is signed using
+// its own region and any named profile embedded within its GRC URL. As credentials
+// are only ever passed as transient config, they are never written to .git/config
+type Updater struct {
+	// Sign is used to generate signed credentials for each CodeCommit submodule
+	Sign gitauth.SignFunc
+
+	// Region resolves a region for any submodule URL that does not contain one
+	Region gitauth.RegionFunc
+
+	// Recursive controls whether nested submodules are also updated
+	Recursive bool
+}
+
+// Update initialises and updates all submodules within a repository, returning
+// details of every CodeCommit submodule. Paths are relative to the repository
+func (u Updater) Update(ctx context.Context, dir string) ([]Submodule, error) {
+	return u.update(ctx, dir, "")
+}
+
+func (u Updater) update(ctx context.Context, dir, prefix string) ([]Submodule, error) {
+	subs, err := List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subs) == 0 {
+		return []Submodule{}, nil
+	}
+
+	// Copy submodule URLs into .git/config, ensuring they are known to later git commands
+	if _, err := git.Run(ctx, dir, "submodule", "init"); err != nil {
+		return nil, err
+	}
+
+	inv := gitauth.Invocation{
+		Args:   []string{"submodule", "update", "--init"},
+		Config: []gitauth.ConfigEntry{},
+	}
+
+	updated := []Submodule{}
+	for _, sub := range subs {
+		url, profile, ok, err := u.resolve(ctx, sub.URL)
+		if err != nil {
+			return updated, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		header, err := gitauth.ExtraHeader(ctx, url, profile, u.Sign)
+		if err != nil {
+			return updated, err
+		}
+
+		// Transiently replace any GRC URL, as git would otherwise require git-remote-codecommit
+		inv.Config = append(inv.Config,
+			gitauth.ConfigEntry{Key: "submodule." + sub.Name + ".url", Value: url},
+			header)
+
+		updated = append(updated, Submodule{Name: sub.Name, Path: filepath.Join(prefix, sub.Path), URL: url})
+	}
+
+	cmd := inv.Command(ctx, os.Environ())
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(redact.String(string(out)))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return updated, errors.New("git submodule: " + msg)
+	}
+
+	if !u.Recursive {
+		return updated, nil
+	}
+
+	for _, sub := range subs {
+		nested, err := u.update(ctx, filepath.Join(dir, sub.Path), filepath.Join(prefix, sub.Path))
+		updated = append(updated, nested...)
+		if err != nil {
+			return updated, err
+		}
+	}
+
+	return updated, nil
+}
+
+// Identifies if a submodule URL targets CodeCommit, returning its unsigned HTTPS
+// equivalent along with any embedded named profile
+func (u Updater) resolve(ctx context.Context, url string) (string, string, bool, error) {
+	var rem translate.Remote
+	var err error
+
+	if strings.HasPrefix(url, "codecommit:") {
+		rem, err = translate.RemoteGRC(url)
+	} else {
+		rem, err = translate.RemoteHTTPS(url)
+	}
+	if err != nil {
+		return "", "", false, nil
+	}
+
+	if rem.Region == "" {
+		if u.Region == nil {
+			return "", "", false, errors.New("no aws region identified")
+		}
+
+		if rem.Region, err = u.Region(ctx, rem.Profile); err != nil {
+			return "", "", false, err
+		}
+	}
+
+	https, err := translate.ToHTTPS(rem)
+	return https, rem.Profile, err == nil, err
+}
@@ -0,0 +1,240 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package submodule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/gembaadvantage/codecommit-sign/internal/gittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSigner records every URL and profile it is asked to sign
+type fakeSigner struct {
+	mu     sync.Mutex
+	signed []string
+}
+
+func (f *fakeSigner) Sign(_ context.Context, url, profile string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.signed = append(f.signed, profile+"|"+url)
+	return url, nil
+}
+
+// remotes creates a directory of bare repositories per region, and configures git to
+// transparently redirect CodeCommit URLs to them
+func remotes(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	config := [][2]string{
+		{"protocol.file.allow", "always"},
+		{"url." + filepath.Join(dir, "eu-west-1") + "/.insteadOf", "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/"},
+		{"url." + filepath.Join(dir, "eu-west-2") + "/.insteadOf", "https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/"},
+	}
+
+	t.Setenv("GIT_CONFIG_COUNT", fmt.Sprint(len(config)))
+	for i, c := range config {
+		t.Setenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", i), c[0])
+		t.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i), c[1])
+	}
+
+	return dir
+}
+
+// addSubmodule adds a submodule to a repository, before replacing its URL
+func addSubmodule(t *testing.T, repo, bare, path, url string) {
+	t.Helper()
+
+	gittest.Git(t, repo, "submodule", "--quiet", "add", bare, path)
+	gittest.Git(t, repo, "config", "--file", ".gitmodules", "submodule."+path+".url", url)
+	gittest.Git(t, repo, "add", ".gitmodules")
+	gittest.Commit(t, repo, "add "+path)
+}
+
+// publish pushes a repository into a bare repository, returning its path
+func publish(t *testing.T, repo, bare string) string {
+	t.Helper()
+
+	gittest.Git(t, "", "clone", "--quiet", "--bare", repo, bare)
+	return bare
+}
+
+func TestUpdate(t *testing.T) {
+	rem := remotes(t)
+	library := gittest.BareRepo(t, filepath.Join(rem, "eu-west-1", "library"))
+	tools := gittest.BareRepo(t, filepath.Join(rem, "eu-west-2", "tools"))
+
+	super := gittest.Repo(t, filepath.Join(t.TempDir(), "super"))
+	addSubmodule(t, super, library, "lib/library", "codecommit::eu-west-1://developer@library")
+	addSubmodule(t, super, tools, "tools", "https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/tools")
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	gittest.Git(t, "", "clone", "--quiet", publish(t, super, filepath.Join(rem, "super")), clone)
+
+	s := &fakeSigner{}
+	u := Updater{Sign: s.Sign}
+
+	updated, err := u.Update(context.Background(), clone)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Submodule{
+		{Name: "lib/library", Path: "lib/library", URL: "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/library"},
+		{Name: "tools", Path: "tools", URL: "https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/tools"},
+	}, updated)
+	assert.Equal(t, []string{
+		"developer|https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/library",
+		"|https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/tools",
+	}, s.signed)
+
+	assert.FileExists(t, filepath.Join(clone, "lib", "library", ".git"))
+	assert.FileExists(t, filepath.Join(clone, "tools", ".git"))
+
+	// The original GRC URL should be retained within the superproject
+	assert.Equal(t, "codecommit::eu-west-1://developer@library", gittest.Git(t, clone, "config", "submodule.lib/library.url"))
+}
+
+func TestUpdate_ResolvesRegion(t *testing.T) {
+	rem := remotes(t)
+	tools := gittest.BareRepo(t, filepath.Join(rem, "eu-west-2", "tools"))
+
+	super := gittest.Repo(t, filepath.Join(t.TempDir(), "super"))
+	addSubmodule(t, super, tools, "tools", "codecommit://ops@tools")
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	gittest.Git(t, "", "clone", "--quiet", publish(t, super, filepath.Join(rem, "super")), clone)
+
+	s := &fakeSigner{}
+	u := Updater{
+		Sign: s.Sign,
+		Region: func(_ context.Context, profile string) (string, error) {
+			require.Equal(t, "ops", profile)
+			return "eu-west-2", nil
+		},
+	}
+
+	_, err := u.Update(context.Background(), clone)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ops|https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/tools"}, s.signed)
+	assert.FileExists(t, filepath.Join(clone, "tools", ".git"))
+}
+
+func TestUpdate_Recursive(t *testing.T) {
+	rem := remotes(t)
+	core := gittest.BareRepo(t, filepath.Join(rem, "eu-west-2", "core"))
+
+	lib := gittest.Repo(t, filepath.Join(t.TempDir(), "library"))
+	addSubmodule(t, lib, core, "core", "https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/core")
+	library := publish(t, lib, filepath.Join(rem, "eu-west-1", "library"))
+
+	super := gittest.Repo(t, filepath.Join(t.TempDir(), "super"))
+	addSubmodule(t, super, library, "library", "codecommit::eu-west-1://library")
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	gittest.Git(t, "", "clone", "--quiet", publish(t, super, filepath.Join(rem, "super")), clone)
+
+	s := &fakeSigner{}
+	u := Updater{Sign: s.Sign, Recursive: true}
+
+	updated, err := u.Update(context.Background(), clone)
+
+	require.NoError(t, err)
+	paths := []string{}
+	for _, sub := range updated {
+		paths = append(paths, sub.Path)
+	}
+	sort.Strings(paths)
+	assert.Equal(t, []string{"library", filepath.Join("library", "core")}, paths)
+	assert.FileExists(t, filepath.Join(clone, "library", "core", ".git"))
+}
+
+func TestUpdate_NotRecursive(t *testing.T) {
+	rem := remotes(t)
+	core := gittest.BareRepo(t, filepath.Join(rem, "eu-west-2", "core"))
+
+	lib := gittest.Repo(t, filepath.Join(t.TempDir(), "library"))
+	addSubmodule(t, lib, core, "core", "https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/core")
+	library := publish(t, lib, filepath.Join(rem, "eu-west-1", "library"))
+
+	super := gittest.Repo(t, filepath.Join(t.TempDir(), "super"))
+	addSubmodule(t, super, library, "library", "codecommit::eu-west-1://library")
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	gittest.Git(t, "", "clone", "--quiet", publish(t, super, filepath.Join(rem, "super")), clone)
+
+	updated, err := Updater{Sign: (&fakeSigner{}).Sign}.Update(context.Background(), clone)
+
+	require.NoError(t, err)
+	assert.Len(t, updated, 1)
+	assert.NoFileExists(t, filepath.Join(clone, "library", "core", ".git"))
+}
+
+func TestUpdate_NoSubmodules(t *testing.T) {
+	dir := gittest.Repo(t, t.TempDir())
+
+	updated, err := Updater{Sign: (&fakeSigner{}).Sign}.Update(context.Background(), dir)
+
+	require.NoError(t, err)
+	assert.Empty(t, updated)
+}
+
+func TestUpdate_NoRegion(t *testing.T) {
+	dir := gittest.Repo(t, t.TempDir())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(`[submodule "tools"]
+	path = tools
+	url = codecommit://tools
+`), 0o644))
+
+	_, err := Updater{Sign: (&fakeSigner{}).Sign}.Update(context.Background(), dir)
+
+	require.EqualError(t, err, "no aws region identified")
+}
+
+func TestUpdate_SignError(t *testing.T) {
+	dir := gittest.Repo(t, t.TempDir())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(`[submodule "tools"]
+	path = tools
+	url = codecommit::eu-west-1://tools
+`), 0o644))
+
+	u := Updater{
+		Sign: func(context.Context, string, string) (string, error) {
+			return "", errors.New("sign error")
+		},
+	}
+
+	_, err := u.Update(context.Background(), dir)
+
+	require.EqualError(t, err, "sign error")
+}
@@ -0,0 +1,124 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package translate
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Form identifies a format of CodeCommit URL
+type Form string
+
+const (
+	// HTTPS is the standard CodeCommit HTTPS clone URL format
+	HTTPS Form = "https"
+
+	// GRC is the git-remote-codecommit URL format
+	GRC Form = "grc"
+
+	// SSH is the CodeCommit SSH clone URL format
+	SSH Form = "ssh"
+)
+
+// To translates any supported CodeCommit URL into the given form. Both the region
+// and any named profile are preserved when translating to a GRC URL, unless the
+// profile is replaced using WithProfile. As HTTPS and SSH
+// URLs require a region, it will be taken from the AWS_REGION or AWS_DEFAULT_REGION
+// environment variables if it cannot be identified from the URL
+func To(url string, form Form, opts ...Option) (string, error) {
+	rem, err := Normalize(url, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	switch form {
+	case GRC:
+		return formatGRC(rem, newOptions(opts))
+	case HTTPS, SSH:
+		if rem.Region == "" {
+			rem.Region = envRegion()
+		}
+
+		if form == SSH {
+			return ToSSH(rem)
+		}
+		return ToHTTPS(rem)
+	}
+
+	return "", fmt.Errorf("unsupported url form %q", form)
+}
+
+// ToSSH constructs a CodeCommit SSH URL from the details of a remote, that can be
+// used to fetch and push changes to a CodeCommit repository
+func ToSSH(rem Remote) (string, error) {
+	if rem.Region == "" {
+		return "", errors.New("no aws region identified")
+	}
+
+	if rem.Repository == "" {
+		return "", errors.New("no codecommit repository identified")
+	}
+
+	return fmt.Sprintf("ssh://%s/v1/repos/%s", host(rem), rem.Repository), nil
+}
+
+// Constructs a GRC URL, including the region and profile only if they are set. Any
+// profile within the options replaces that of the remote. A GRC URL cannot target a
+// FIPS endpoint, so translating one is rejected rather than silently dropping it
+func formatGRC(rem Remote, o options) (string, error) {
+	if rem.FIPS {
+		return "", errors.New("codecommit FIPS endpoints cannot be expressed as a GRC URL")
+	}
+
+	if o.profile != "" {
+		rem.Profile = o.profile
+	}
+
+	grc := "codecommit:"
+	if rem.Region != "" {
+		grc += ":" + rem.Region + ":"
+	}
+	grc += "//"
+
+	if rem.Profile != "" {
+		grc += rem.Profile + "@"
+	}
+	return grc + rem.Repository, nil
+}
+
+// Constructs the hostname of the CodeCommit git endpoint for a remote
+func host(rem Remote) string {
+	prefix := "git-codecommit"
+	if rem.FIPS {
+		prefix += "-fips"
+	}
+	return fmt.Sprintf("%s.%s.%s", prefix, rem.Region, domain(rem.Region))
+}
+
+func domain(region string) string {
+	if region == "cn-north-1" || region == "cn-northwest-1" {
+		return "amazonaws.com.cn"
+	}
+	return "amazonaws.com"
+}
@@ -0,0 +1,131 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package translate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		form     Form
+		expected string
+	}{
+		{
+			name:     "HTTPSToGRC",
+			url:      "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+			form:     GRC,
+			expected: "codecommit::eu-west-1://repository",
+		},
+		{
+			name:     "HTTPSToSSH",
+			url:      "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+			form:     SSH,
+			expected: "ssh://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name:     "GRCToGRCPreservesProfile",
+			url:      "codecommit::eu-west-2://profile@repository",
+			form:     GRC,
+			expected: "codecommit::eu-west-2://profile@repository",
+		},
+		{
+			name:     "GRCToGRCPreservesMissingRegion",
+			url:      "codecommit://profile@repository",
+			form:     GRC,
+			expected: "codecommit://profile@repository",
+		},
+		{
+			name:     "GRCToHTTPS",
+			url:      "codecommit::eu-west-2://profile@repository",
+			form:     HTTPS,
+			expected: "https://git-codecommit.eu-west-2.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name:     "GRCToHTTPSDefaultRegion",
+			url:      "codecommit://repository",
+			form:     HTTPS,
+			expected: "https://git-codecommit.us-east-1.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name:     "SSHToHTTPS",
+			url:      "ssh://APKAEIBAERJR2EXAMPLE@git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+			form:     HTTPS,
+			expected: "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name:     "ConsoleToGRC",
+			url:      "https://eu-west-1.console.aws.amazon.com/codesuite/codecommit/repositories/repository/browse",
+			form:     GRC,
+			expected: "codecommit::eu-west-1://repository",
+		},
+		{
+			name:     "FIPSHTTPSToSSH",
+			url:      "https://git-codecommit-fips.us-east-1.amazonaws.com/v1/repos/repository",
+			form:     SSH,
+			expected: "ssh://git-codecommit-fips.us-east-1.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name:     "ChinaRegionToSSH",
+			url:      "codecommit::cn-north-1://repository",
+			form:     SSH,
+			expected: "ssh://git-codecommit.cn-north-1.amazonaws.com.cn/v1/repos/repository",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_REGION", "us-east-1")
+
+			actual, err := To(tt.url, tt.form)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTo_NoRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	_, err := To("codecommit://repository", SSH)
+
+	require.EqualError(t, err, "no aws region identified")
+}
+
+func TestTo_UnsupportedForm(t *testing.T) {
+	_, err := To("codecommit::eu-west-1://repository", Form("git"))
+
+	require.EqualError(t, err, `unsupported url form "git"`)
+}
+
+func TestTo_UnsupportedURL(t *testing.T) {
+	_, err := To("https://github.com/org/repository.git", GRC)
+
+	require.Error(t, err)
+}
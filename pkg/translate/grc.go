@@ -30,34 +30,55 @@ import (
 
 // ToGrc translates a CodeCommit HTTPS URL to a compatible CodeCommit (git-remote-codecommit)
 // GRC based URL that can be used to fetch and push changes to a CodeCommit repository
-func ToGRC(url string) (string, error) {
+func ToGRC(url string, opts ...Option) (string, error) {
 	rem, err := RemoteHTTPS(url)
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("codecommit::%s://%s", rem.Region, rem.Repository), nil
+	return formatGRC(rem, newOptions(opts))
 }
 
 // FromGrc translates a CodeCommit (git-remote-codecommit) GRC URL to a compatible HTTPS URL
-// that can be used to fetch and push changes to a CodeCommit repository
-func FromGRC(url string) (string, error) {
+// that can be used to fetch and push changes to a CodeCommit repository. If the GRC URL
+// contains no region, it is taken from the AWS_REGION or AWS_DEFAULT_REGION environment
+// variables
+func FromGRC(url string, opts ...Option) (string, error) {
 	rem, err := RemoteGRC(url)
 	if err != nil {
 		return "", err
 	}
 
-	// If a region is not set, check if one is provided through the AWS_REGION environment variable
+	// If a region is not set, check if one is provided through the environment
 	if rem.Region == "" {
-		if rem.Region = os.Getenv("AWS_REGION"); rem.Region == "" {
+		if rem.Region = envRegion(); rem.Region == "" {
 			return "", errors.New("no aws region identified")
 		}
+		newOptions(opts).logger.Debug("region resolved from environment", "repository", rem.Repository, "region", rem.Region)
 	}
 
-	domain := "amazonaws.com"
-	if rem.Region == "cn-north-1" || rem.Region == "cn-northwest-1" {
-		domain = "amazonaws.com.cn"
+	return ToHTTPS(rem)
+}
+
+// Identifies a region from either the AWS_REGION or AWS_DEFAULT_REGION environment
+// variables, in that order
+func envRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// ToHTTPS constructs a CodeCommit HTTPS URL from the details of a remote, that can be
+// used to fetch and push changes to a CodeCommit repository
+func ToHTTPS(rem Remote) (string, error) {
+	if rem.Region == "" {
+		return "", errors.New("no aws region identified")
+	}
+
+	if rem.Repository == "" {
+		return "", errors.New("no codecommit repository identified")
 	}
 
-	return fmt.Sprintf("https://git-codecommit.%s.%s/v1/repos/%s", rem.Region, domain, rem.Repository), nil
+	return fmt.Sprintf("https://%s/v1/repos/%s", host(rem), rem.Repository), nil
 }
@@ -57,6 +57,33 @@ func TestToGRC(t *testing.T) {
 	}
 }
 
+func TestToGRC_WithProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  string
+		expected string
+	}{
+		{
+			name:     "NamedProfile",
+			profile:  "profile",
+			expected: "codecommit::eu-west-1://profile@repository",
+		},
+		{
+			name:     "EmptyProfile",
+			profile:  "",
+			expected: "codecommit::eu-west-1://repository",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ToGRC("https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository", WithProfile(tt.profile))
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
 func TestToGRC_MalformedURL(t *testing.T) {
 	url, err := ToGRC("https://git-codecommit..amazonaws.com/v1/repos/repository")
 
@@ -64,6 +91,12 @@ func TestToGRC_MalformedURL(t *testing.T) {
 	assert.Equal(t, "", url)
 }
 
+func TestToGRC_FIPS(t *testing.T) {
+	_, err := ToGRC("https://git-codecommit-fips.us-east-1.amazonaws.com/v1/repos/repository")
+
+	require.EqualError(t, err, "codecommit FIPS endpoints cannot be expressed as a GRC URL")
+}
+
 func TestFromGRC(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -135,8 +168,29 @@ func TestFromGRC_ChinaRegions(t *testing.T) {
 	}
 }
 
+func TestFromGRC_DefaultRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-3")
+
+	url, err := FromGRC("codecommit://repository")
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://git-codecommit.eu-west-3.amazonaws.com/v1/repos/repository", url)
+}
+
+func TestFromGRC_RegionPrecedence(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-3")
+
+	url, err := FromGRC("codecommit://repository")
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository", url)
+}
+
 func TestFromGRC_NoRegionSet(t *testing.T) {
 	os.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
 
 	url, err := FromGRC("codecommit://repository")
 
@@ -150,3 +204,54 @@ func TestFromGrc_MalformedURL(t *testing.T) {
 	require.Error(t, err)
 	assert.Equal(t, "", url)
 }
+
+func TestToHTTPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		remote   Remote
+		expected string
+	}{
+		{
+			name:     "Region",
+			remote:   Remote{Region: "eu-west-1", Repository: "repository"},
+			expected: "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name:     "ChinaRegion",
+			remote:   Remote{Region: "cn-north-1", Repository: "repository"},
+			expected: "https://git-codecommit.cn-north-1.amazonaws.com.cn/v1/repos/repository",
+		},
+		{
+			name:     "IgnoresNamedProfile",
+			remote:   Remote{Region: "eu-west-1", Repository: "repository", Profile: "profile"},
+			expected: "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+		},
+		{
+			name:     "FIPS",
+			remote:   Remote{Region: "us-east-1", Repository: "repository", FIPS: true},
+			expected: "https://git-codecommit-fips.us-east-1.amazonaws.com/v1/repos/repository",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ToHTTPS(tt.remote)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestToHTTPS_NoRegion(t *testing.T) {
+	url, err := ToHTTPS(Remote{Repository: "repository"})
+
+	require.EqualError(t, err, "no aws region identified")
+	assert.Equal(t, "", url)
+}
+
+func TestToHTTPS_NoRepository(t *testing.T) {
+	url, err := ToHTTPS(Remote{Region: "eu-west-1"})
+
+	require.EqualError(t, err, "no codecommit repository identified")
+	assert.Equal(t, "", url)
+}
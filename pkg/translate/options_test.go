@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2022 Gemba Advantage
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func jsonLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	_, err := Normalize("codecommit::eu-west-1://profile@repository", WithLogger(jsonLogger(&buf)))
+	require.NoError(t, err)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "normalized codecommit url", entry["msg"])
+	assert.Equal(t, "grc", entry["form"])
+	assert.Equal(t, "repository", entry["repository"])
+	assert.Equal(t, "eu-west-1", entry["region"])
+	assert.Equal(t, "profile", entry["profile"])
+}
+
+func TestWithLogger_RegionFromEnv(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-2")
+	var buf bytes.Buffer
+
+	_, err := FromGRC("codecommit://repository", WithLogger(jsonLogger(&buf)))
+	require.NoError(t, err)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "region resolved from environment", entry["msg"])
+	assert.Equal(t, "eu-west-2", entry["region"])
+}
+
+func TestWithLogger_DiscardsByDefault(t *testing.T) {
+	assert.False(t, newOptions(nil).logger.Enabled(t.Context(), slog.LevelError))
+	assert.False(t, newOptions([]Option{WithLogger(nil)}).logger.Enabled(t.Context(), slog.LevelError))
+}
+
+func TestWithProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{
+			name:     "HTTPS",
+			url:      "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/repository",
+			expected: "codecommit::eu-west-1://profile@repository",
+		},
+		{
+			name:     "GRCNamedProfile",
+			url:      "codecommit::eu-west-1://other@repository",
+			expected: "codecommit::eu-west-1://profile@repository",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := To(tt.url, GRC, WithProfile("profile"), WithLogger(nil))
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -24,15 +24,22 @@ package translate
 
 import (
 	"errors"
+	"net/url"
 	"regexp"
 	"strings"
 )
 
 var (
-	urlRgx = regexp.MustCompile(`^https://(.+@)?git-codecommit\.(.+)\.(amazonaws\.com|amazonaws\.com\.cn)/v1/repos/(.+)$`)
-	grcRgx = regexp.MustCompile(`^codecommit:(:.+:)?//(.+)$`)
+	urlRgx     = regexp.MustCompile(`^https://(.+@)?git-codecommit(-fips)?\.(.+)\.(amazonaws\.com|amazonaws\.com\.cn)/v1/repos/(.+)$`)
+	grcRgx     = regexp.MustCompile(`^codecommit:(:.+:)?//(.+)$`)
+	sshRgx     = regexp.MustCompile(`^ssh://([^@/]+@)?git-codecommit(-fips)?\.(.+)\.(amazonaws\.com|amazonaws\.com\.cn)/v1/repos/(.+)$`)
+	consoleRgx = regexp.MustCompile(`^https://(?:([a-z0-9-]+)\.)?console\.(?:aws\.amazon\.com|amazonaws\.cn)/codesuite/codecommit/repositories/([^/?#]+)`)
+	hostRgx    = regexp.MustCompile(`^(https|ssh)://([^@/]+@)?git-codecommit(-fips)?\.`)
 )
 
+// ErrNotCodeCommit is returned when a URL does not target a CodeCommit repository
+var ErrNotCodeCommit = errors.New("not a codecommit URL")
+
 // Remote provides both details about how the repository is hosted within AWS
 // CodeCommit and it will be accessed
 type Remote struct {
@@ -44,19 +51,24 @@ type Remote struct {
 
 	// Profile identifies if a named AWS Profile was used when targeting the remote
 	Profile string
+
+	// FIPS identifies if the remote targets a FIPS endpoint (git-codecommit-fips).
+	// A GRC URL has no way of expressing this
+	FIPS bool
 }
 
 // RemoteHTTPS identifies details about an AWS CodeCommit remote based on the provided
 // HTTPS clone URL
 func RemoteHTTPS(url string) (Remote, error) {
 	m := urlRgx.FindStringSubmatch(url)
-	if len(m) < 5 {
+	if len(m) < 6 {
 		return Remote{}, errors.New("malformed codecommit HTTPS URL")
 	}
 
 	return Remote{
 		Repository: m[len(m)-1],
-		Region:     m[2],
+		Region:     m[3],
+		FIPS:       m[2] != "",
 	}, nil
 }
 
@@ -83,3 +95,82 @@ func RemoteGRC(url string) (Remote, error) {
 
 	return rem, nil
 }
+
+// RemoteSSH identifies details about an AWS CodeCommit remote based on the provided
+// SSH clone URL. Any SSH key ID within the URL is ignored
+func RemoteSSH(url string) (Remote, error) {
+	m := sshRgx.FindStringSubmatch(url)
+	if len(m) < 6 {
+		return Remote{}, errors.New("malformed codecommit SSH URL")
+	}
+
+	return Remote{
+		Repository: m[len(m)-1],
+		Region:     m[3],
+		FIPS:       m[2] != "",
+	}, nil
+}
+
+// RemoteConsole identifies details about an AWS CodeCommit remote based on the URL of
+// any repository page within the AWS console. The region is taken from the console
+// hostname, falling back to the region query parameter
+func RemoteConsole(consoleURL string) (Remote, error) {
+	m := consoleRgx.FindStringSubmatch(consoleURL)
+	if len(m) < 3 {
+		return Remote{}, errors.New("malformed codecommit console URL")
+	}
+
+	rem := Remote{
+		Repository: m[2],
+		Region:     m[1],
+	}
+
+	if rem.Region == "" {
+		if u, err := url.Parse(consoleURL); err == nil {
+			rem.Region = u.Query().Get("region")
+		}
+	}
+
+	return rem, nil
+}
+
+// Normalize identifies details about an AWS CodeCommit remote from any supported URL.
+// That includes HTTPS, GRC (git-remote-codecommit) and SSH clone URLs, along with the
+// URL of any repository page within the AWS console. ErrNotCodeCommit is returned if
+// the URL does not target CodeCommit at all
+func Normalize(url string, opts ...Option) (Remote, error) {
+	var (
+		rem  Remote
+		err  error
+		form string
+	)
+
+	switch {
+	case strings.HasPrefix(url, "codecommit:"):
+		form = "grc"
+		rem, err = RemoteGRC(url)
+	case consoleRgx.MatchString(url):
+		form = "console"
+		rem, err = RemoteConsole(url)
+	case hostRgx.MatchString(url):
+		form = hostRgx.FindStringSubmatch(url)[1]
+		if form == "ssh" {
+			rem, err = RemoteSSH(url)
+		} else {
+			rem, err = RemoteHTTPS(url)
+		}
+	default:
+		return Remote{}, ErrNotCodeCommit
+	}
+
+	if err != nil {
+		return Remote{}, err
+	}
+
+	newOptions(opts).logger.Debug("normalized codecommit url",
+		"form", form,
+		"repository", rem.Repository,
+		"region", rem.Region,
+		"profile", rem.Profile)
+	return rem, nil
+}